@@ -0,0 +1,175 @@
+package yeahno
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/mhpenta/tap-go"
+)
+
+// tapErrForbidden is the TAP error code for authenticated callers that
+// lack a role an option requires. tap-go has no constant for it.
+const tapErrForbidden = "forbidden"
+
+// Principal is an authenticated TAP caller.
+type Principal struct {
+	// Name identifies the caller, e.g. a service name or certificate CN.
+	Name string
+	// Roles are matched against the roles options list in Require.
+	Roles []string
+}
+
+// HasRole reports whether the principal has role. A nil principal has no
+// roles.
+func (p *Principal) HasRole(role string) bool {
+	return p != nil && slices.Contains(p.Roles, role)
+}
+
+// Authenticator identifies the caller of a TAP request. It returns an error
+// if the request carries no valid credentials.
+type Authenticator func(r *http.Request) (*Principal, error)
+
+// TAPOptions configures RegisterTAPWith.
+type TAPOptions struct {
+	// Authenticate is called for every request. Requests it rejects get an
+	// unauthorized error. Nil allows anonymous callers, who can only use
+	// options without Require.
+	Authenticate Authenticator
+	// OpenAPI also serves the Select's OpenAPI document at /openapi.json,
+	// behind the same authentication. Each caller only sees the tools it
+	// may use.
+	OpenAPI bool
+}
+
+// Require restricts the option to TAP callers whose Principal has at least
+// one of roles. Other callers don't see it in the tool index and get
+// unauthorized (anonymous) or forbidden (authenticated) when they call it.
+// Requiring roles on a submenu applies them to every option in it.
+//
+// MCP, CLI and TUI callers are not authenticated by yeahno; use
+// VisibleWhen to restrict those.
+func (o Option[T]) Require(roles ...string) Option[T] {
+	o.roles = append(slices.Clone(o.roles), roles...)
+	return o
+}
+
+// allows reports whether p has at least one of roles, or roles is empty.
+func (p *Principal) allows(roles []string) bool {
+	return len(roles) == 0 || slices.ContainsFunc(roles, p.HasRole)
+}
+
+// forbiddenTools returns the names of tools p may not use.
+func (s *Select[T]) forbiddenTools(p *Principal) map[string]bool {
+	forbidden := make(map[string]bool)
+	for _, info := range s.optionInfos(s.rootScope()) {
+		if !p.allows(info.roles) {
+			forbidden[info.name] = true
+		}
+	}
+	return forbidden
+}
+
+var errNoCredentials = errors.New("missing credentials")
+
+var errBadCredentials = errors.New("invalid credentials")
+
+// BearerToken authenticates requests by their "Authorization: Bearer"
+// token, mapping each accepted token to its principal.
+func BearerToken(tokens map[string]*Principal) Authenticator {
+	return func(r *http.Request) (*Principal, error) {
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			return nil, errNoCredentials
+		}
+		return lookupSecret(tokens, token)
+	}
+}
+
+// APIKey authenticates requests by the key sent in header, such as
+// "X-API-Key", mapping each accepted key to its principal.
+func APIKey(header string, keys map[string]*Principal) Authenticator {
+	return func(r *http.Request) (*Principal, error) {
+		key := r.Header.Get(header)
+		if key == "" {
+			return nil, errNoCredentials
+		}
+		return lookupSecret(keys, key)
+	}
+}
+
+// ClientCertificate authenticates mutual TLS requests by the common name
+// of the verified client certificate. The server's tls.Config must verify
+// client certificates (tls.RequireAndVerifyClientCert or
+// VerifyClientCertIfGiven). If principals is nil every verified peer is
+// accepted as a principal named after its common name, without roles.
+func ClientCertificate(principals map[string]*Principal) Authenticator {
+	return func(r *http.Request) (*Principal, error) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			return nil, errNoCredentials
+		}
+		name := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		if principals == nil {
+			return &Principal{Name: name}, nil
+		}
+		p, ok := principals[name]
+		if !ok {
+			return nil, errBadCredentials
+		}
+		return p, nil
+	}
+}
+
+// AnyOf tries each authenticator in turn and accepts the first principal
+// one returns, e.g. to allow either a client certificate or a token.
+func AnyOf(auths ...Authenticator) Authenticator {
+	return func(r *http.Request) (*Principal, error) {
+		err := errNoCredentials
+		for _, auth := range auths {
+			p, authErr := auth(r)
+			if authErr == nil {
+				return p, nil
+			}
+			// Report bad credentials over missing ones
+			if !errors.Is(authErr, errNoCredentials) {
+				err = authErr
+			}
+		}
+		return nil, err
+	}
+}
+
+// lookupSecret finds the principal for secret, comparing in constant time.
+func lookupSecret(secrets map[string]*Principal, secret string) (*Principal, error) {
+	var found *Principal
+	for s, p := range secrets {
+		if subtle.ConstantTimeCompare([]byte(s), []byte(secret)) == 1 {
+			found = p
+		}
+	}
+	if found == nil {
+		return nil, errBadCredentials
+	}
+	return found, nil
+}
+
+type principalKey struct{}
+
+// authenticateTAP rejects requests opts.Authenticate does not accept and
+// stores the principal of accepted ones for tapInvocation.
+func authenticateTAP(opts *TAPOptions, next http.Handler) http.Handler {
+	if opts == nil || opts.Authenticate == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := opts.Authenticate(r)
+		if err != nil {
+			writeTAPError(w, http.StatusUnauthorized, tap.ErrUnauthorized, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	})
+}
@@ -1,10 +1,16 @@
 package yeahno
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"slices"
 	"strings"
 
+	"charm.land/lipgloss/v2"
 	"github.com/spf13/cobra"
 )
 
@@ -12,10 +18,6 @@ import (
 // Each MCP-enabled option becomes a subcommand.
 // Fields become flags on the subcommand.
 func (s *Select[T]) ToCLI() (*cobra.Command, error) {
-	if s.handler == nil {
-		return nil, fmt.Errorf("no handler configured")
-	}
-
 	// Build root command from select metadata
 	rootName := toSnakeCase(s.title)
 	if s.toolPrefix != "" {
@@ -26,23 +28,14 @@ func (s *Select[T]) ToCLI() (*cobra.Command, error) {
 		Use:   rootName,
 		Short: s.description,
 	}
-
-	// Determine which options to include
-	var cliOptions []Option[T]
-	for _, o := range s.options {
-		if o.mcp {
-			cliOptions = append(cliOptions, o)
-		}
-	}
-	if len(cliOptions) == 0 {
-		cliOptions = s.options
-	}
+	addPersistentFlags(root, nil)
 
 	// Create subcommand for each option
-	for _, opt := range cliOptions {
-		cmd := s.buildSubcommand(opt)
-		root.AddCommand(cmd)
+	cmds, err := s.subcommands(s.rootScope())
+	if err != nil {
+		return nil, err
 	}
+	root.AddCommand(cmds...)
 
 	return root, nil
 }
@@ -50,34 +43,213 @@ func (s *Select[T]) ToCLI() (*cobra.Command, error) {
 // ToSubcommands generates Cobra subcommands without a root wrapper.
 // Use this to attach commands directly to an existing Cobra root.
 func (s *Select[T]) ToSubcommands() ([]*cobra.Command, error) {
-	if s.handler == nil {
-		return nil, fmt.Errorf("no handler configured")
+	return s.toSubcommands(nil)
+}
+
+// toSubcommands generates the subcommands to be attached to parent, which
+// may be nil when it is not known yet.
+func (s *Select[T]) toSubcommands(parent *cobra.Command) ([]*cobra.Command, error) {
+	cmds, err := s.subcommands(s.rootScope())
+	if err != nil {
+		return nil, err
+	}
+	for _, cmd := range cmds {
+		addPersistentFlags(cmd, parent)
 	}
+	return cmds, nil
+}
 
-	var cliOptions []Option[T]
-	for _, o := range s.options {
-		if o.mcp {
-			cliOptions = append(cliOptions, o)
+// addPersistentFlags adds the flags shared by all generated commands:
+// --output/-o, which selects how results are printed, --config and
+// --no-input. Flags and shorthands that parent or its ancestors already
+// define as persistent flags are left out, as Cobra panics on redefining
+// them; the host's flag is used instead.
+func addPersistentFlags(cmd, parent *cobra.Command) {
+	flags := cmd.PersistentFlags()
+	if !inheritsFlag(parent, "config") {
+		flags.String("config", "", "Read default field values from this YAML or JSON file")
+	}
+	if !inheritsFlag(parent, "no-input") {
+		flags.Bool("no-input", false, "Never prompt; fail when required flags are missing")
+	}
+	if !inheritsFlag(parent, "output") {
+		short := "o"
+		if inheritsShorthand(parent, short) {
+			short = ""
 		}
+		flags.StringP("output", short, "", "Output format: json, yaml, table, text or template='<go template>'")
+		cmd.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return outputFormats, cobra.ShellCompDirectiveNoFileComp
+		})
 	}
-	if len(cliOptions) == 0 {
-		cliOptions = s.options
+}
+
+// inheritsFlag reports whether parent or one of its ancestors defines the
+// persistent flag name.
+func inheritsFlag(parent *cobra.Command, name string) bool {
+	for c := parent; c != nil; c = c.Parent() {
+		if c.PersistentFlags().Lookup(name) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// inheritsShorthand is inheritsFlag for a one-letter shorthand.
+func inheritsShorthand(parent *cobra.Command, short string) bool {
+	for c := parent; c != nil; c = c.Parent() {
+		if c.PersistentFlags().ShorthandLookup(short) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// cliFieldSource returns the environment and config lookup for a command.
+// --config overrides the Select's ConfigFile and must exist.
+func (s *Select[T]) cliFieldSource(cmd *cobra.Command, sc scope, opt Option[T]) (fieldSource, error) {
+	path, _ := cmd.Flags().GetString("config")
+	required := path != ""
+	if path == "" {
+		path = sc.configFile
+	}
+	config, err := loadConfig(path, required)
+	if err != nil {
+		return fieldSource{}, err
+	}
+	return fieldSource{
+		envPrefix: sc.envPrefix,
+		config:    config,
+		tool:      joinToolName(sc.prefix, opt.name()),
+	}, nil
+}
+
+// missingFieldError reports a required field given neither as an argument
+// nor as a flag.
+func missingFieldError(f *Input) error {
+	if f.positional {
+		return fmt.Errorf("required argument %s not provided", f.argUsage())
+	}
+	return fmt.Errorf("required flag --%s not provided", toKebabCase(f.fieldKey()))
+}
+
+// interactive reports whether the command may prompt: stdin is a terminal
+// not used for --input, and --no-input was not given.
+func interactive(cmd *cobra.Command) bool {
+	noInput, _ := cmd.Flags().GetBool("no-input")
+	input, _ := cmd.Flags().GetString("input")
+	return !noInput && input != "-" && isTerminal(cmd.InOrStdin())
+}
+
+// readInputArgs reads the --input arguments object from path, or from
+// stdin for "-". The object has the same shape as MCP and TAP arguments.
+func readInputArgs(cmd *cobra.Command, path string) (map[string]any, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(cmd.InOrStdin())
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --input: %w", err)
+	}
+	input, err := decodeObject(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --input: %w", err)
+	}
+	return input, nil
+}
+
+// cliOutputFormat returns the format chosen with --output. Without one,
+// options that declare an Output print tables and others print text.
+func cliOutputFormat(cmd *cobra.Command, declared bool) (outputFormat, error) {
+	value, _ := cmd.Flags().GetString("output")
+	format, err := parseOutputFormat(value)
+	if err != nil {
+		return format, err
+	}
+	if format.name == "" {
+		format.name = outputText
+		if declared {
+			format.name = outputTable
+		}
+	}
+	return format, nil
+}
+
+func (s *Select[T]) subcommands(sc scope) ([]*cobra.Command, error) {
+	cliOptions := s.exposedOptions()
+	if err := s.checkHandlers(cliOptions); err != nil {
+		return nil, err
 	}
 
 	var cmds []*cobra.Command
 	for _, opt := range cliOptions {
-		cmds = append(cmds, s.buildSubcommand(opt))
+		cmd, err := s.buildCommand(sc, opt)
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, cmd)
 	}
 
 	return cmds, nil
 }
 
-func (s *Select[T]) buildSubcommand(opt Option[T]) *cobra.Command {
-	cmdName := opt.toolName
-	if cmdName == "" {
-		cmdName = opt.Key
+// buildCommand returns the command for an option: a group command for
+// submenus, otherwise a runnable subcommand.
+func (s *Select[T]) buildCommand(sc scope, opt Option[T]) (*cobra.Command, error) {
+	// Options hidden from CLI users stay callable but are left out of help;
+	// invoke rejects them when they run
+	caller := callerContext(context.Background(), Invocation{Surface: SurfaceCLI}, joinToolName(sc.prefix, opt.name()), opt)
+	hidden := !opt.visibleTo(caller)
+
+	if opt.submenu == nil {
+		cmd, err := s.buildSubcommand(sc, opt)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Hidden = hidden
+		return cmd, nil
+	}
+
+	desc := opt.desc
+	if desc == "" {
+		desc = opt.Key
+	}
+
+	group := &cobra.Command{
+		Use:    toKebabCase(opt.name()),
+		Short:  desc,
+		Hidden: hidden,
+	}
+	children, err := opt.submenu.subcommands(s.childScope(sc, opt))
+	if err != nil {
+		return nil, err
+	}
+	group.AddCommand(children...)
+	return group, nil
+}
+
+// reservedFlags are the flags every generated command has. Fields can't
+// use them as names.
+var reservedFlags = []string{"config", "help", "input", "no-input", "output", "output-file", "yes"}
+
+func (s *Select[T]) buildSubcommand(sc scope, opt Option[T]) (*cobra.Command, error) {
+	cmdName := toKebabCase(opt.name())
+
+	pos, err := positionalFields(opt.fields)
+	if err == nil {
+		err = checkEnums(opt.fields)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("command %s: %w", cmdName, err)
+	}
+	for _, f := range opt.fields {
+		if flagName := toKebabCase(f.fieldKey()); slices.Contains(reservedFlags, flagName) {
+			return nil, fmt.Errorf("command %s: field %s: --%s is a built-in flag", cmdName, f.fieldKey(), flagName)
+		}
 	}
-	cmdName = toKebabCase(cmdName)
 
 	desc := opt.desc
 	if desc == "" {
@@ -88,25 +260,28 @@ func (s *Select[T]) buildSubcommand(opt Option[T]) *cobra.Command {
 	// Show first 2 required flags, then "(+N more)" if there are more
 	const maxShownFlags = 2
 	usageParts := []string{cmdName}
+	for _, f := range pos {
+		usageParts = append(usageParts, f.argUsage())
+	}
 	var requiredFlags []string
 	for _, f := range opt.fields {
-		if f.required {
-			fKey := f.key
-			if fKey == "" {
-				fKey = toSnakeCase(f.title)
+		if f.required && !f.positional {
+			flagName := toKebabCase(f.fieldKey())
+			if f.kind == KindBoolean {
+				requiredFlags = append(requiredFlags, "--"+flagName)
+			} else {
+				requiredFlags = append(requiredFlags, fmt.Sprintf("--%s <value>", flagName))
 			}
-			flagName := toKebabCase(fKey)
-			requiredFlags = append(requiredFlags, fmt.Sprintf("--%s <value>", flagName))
 		}
 	}
-	
+
 	if len(requiredFlags) <= maxShownFlags {
 		usageParts = append(usageParts, requiredFlags...)
 	} else {
 		usageParts = append(usageParts, requiredFlags[:maxShownFlags]...)
 		usageParts = append(usageParts, fmt.Sprintf("(+%d more)", len(requiredFlags)-maxShownFlags))
 	}
-	
+
 	// Add [flags] if there are optional flags
 	hasOptional := false
 	for _, f := range opt.fields {
@@ -120,79 +295,218 @@ func (s *Select[T]) buildSubcommand(opt Option[T]) *cobra.Command {
 	}
 	useString := strings.Join(usageParts, " ")
 
-	// Track flag values
-	flagValues := make(map[string]*string)
+	long := desc
+	if hints := opt.hints(); len(hints) > 0 {
+		long += "\n\nBehavior: " + strings.Join(hints, ", ")
+	}
 
 	cmd := &cobra.Command{
-		Use:   useString,
-		Short: desc,
+		Use:               useString,
+		Short:             desc,
+		Long:              long,
+		Args:              positionalArgs(pos),
+		ValidArgsFunction: completeArgs(pos, opt.fields),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			// Collect field values from flags
+			// Unavailable commands fail before prompting for anything
+			inv := &Invocation{Surface: SurfaceCLI, CommandPath: cmd.CommandPath()}
+			if err := checkCaller(cmd.Context(), *inv, joinToolName(sc.prefix, opt.name()), opt); err != nil {
+				return err
+			}
+
+			// Reject a bad --output before running anything
+			format, err := cliOutputFormat(cmd, opt.outputSchema != nil)
+			if err != nil {
+				return err
+			}
+
+			// Arguments from --input fill in fields without a flag
+			var input map[string]any
+			if path, _ := cmd.Flags().GetString("input"); path != "" {
+				if input, err = readInputArgs(cmd, path); err != nil {
+					return err
+				}
+			}
+
+			// Fields not given explicitly fall back to the environment,
+			// then the config file
+			source, err := s.cliFieldSource(cmd, sc, opt)
+			if err != nil {
+				return err
+			}
+
+			// Collect field values from arguments and flags
+			argValues := positionalValues(pos, args)
 			fields := make(map[string]string)
+			var missing []*Input
 			for _, f := range opt.fields {
-				fKey := f.key
-				if fKey == "" {
-					fKey = toSnakeCase(f.title)
-				}
+				fKey := f.fieldKey()
+				flagName := toKebabCase(fKey)
 
-				if val, ok := flagValues[fKey]; ok && val != nil && *val != "" {
-					// Validate format if specified
-					if f.format != "" {
-						if err := ValidateFormat(f.format, *val); err != nil {
-							return fmt.Errorf("invalid %s: %w", fKey, err)
-						}
+				raw, fromArg := argValues[fKey]
+				flag := cmd.Flags().Lookup(flagName)
+				if flag != nil && flag.Changed && flag.Value.String() != "" {
+					if fromArg {
+						return fmt.Errorf("%s given both as an argument and as --%s", fKey, flagName)
 					}
-					// Run custom validation
-					if f.validate != nil {
-						if err := f.validate(*val); err != nil {
-							return fmt.Errorf("invalid %s: %w", fKey, err)
+					raw = flag.Value.String()
+				} else if !fromArg {
+					if v, ok := input[fKey]; ok && v != nil {
+						val, err := f.collect(v)
+						if err != nil {
+							return err
 						}
+						fields[fKey] = val
+						continue
+					}
+					val, ok, err := source.lookup(f)
+					if err != nil {
+						return err
+					}
+					if ok {
+						fields[fKey] = val
+						continue
+					}
+					if f.required {
+						missing = append(missing, f)
+					}
+					continue
+				}
+
+				// Typed flags are already parsed by Cobra; parseValue
+				// normalizes them, parses arguments and checks enums
+				val, err := f.parseValue(raw)
+				if err != nil {
+					return fmt.Errorf("invalid %s: %w", fKey, err)
+				}
+				// Validate format if specified
+				if f.format != "" {
+					if err := ValidateFormat(f.format, val); err != nil {
+						return fmt.Errorf("invalid %s: %w", fKey, err)
+					}
+				}
+				// Run custom validation
+				if f.validate != nil {
+					if err := f.validate(val); err != nil {
+						return fmt.Errorf("invalid %s: %w", fKey, err)
+					}
+				}
+				fields[fKey] = val
+			}
+
+			// On a terminal, ask for missing required fields with the
+			// same prompts as the TUI
+			for _, f := range missing {
+				if !interactive(cmd) {
+					return missingFieldError(f)
+				}
+				val, err := s.promptField(cmd.Context(), f, fields)
+				if err != nil {
+					return err
+				}
+				if val == "" {
+					return missingFieldError(f)
+				}
+				fields[f.fieldKey()] = val
+			}
+
+			// Destructive commands need --yes, or an interactive confirmation
+			if opt.needsConfirm() {
+				yes, _ := cmd.Flags().GetBool("yes")
+				if !yes {
+					msg := opt.confirmMessage(fields)
+					if !interactive(cmd) {
+						return fmt.Errorf("%s Pass --yes to confirm", msg)
+					}
+					ok, err := promptConfirm(msg, s.theme)
+					if err != nil {
+						return err
+					}
+					if !ok {
+						return ErrNotConfirmed
 					}
-					fields[fKey] = *val
-				} else if f.required {
-					return fmt.Errorf("required flag --%s not provided", toKebabCase(fKey))
 				}
 			}
 
-			// Call handler
-			result, err := s.handler(cmd.Context(), opt.Value, fields)
+			// Call handler, reporting progress on stderr and printing
+			// streamed chunks as they arrive
+			progress := newProgressPrinter(cmd.ErrOrStderr(), isTerminal(cmd.ErrOrStderr()))
+			ctx := withProgress(cmd.Context(), progress.report)
+			ctx, stream := withStream(ctx, func(chunk any) error {
+				progress.interrupt(func() { fmt.Fprintln(cmd.OutOrStdout(), format.renderChunk(chunk)) })
+				return nil
+			})
+			result, err := s.invoke(ctx, sc, inv, opt, fields)
+			progress.finish()
 			if err != nil {
 				return err
 			}
+			if result == nil && stream.streamed() {
+				return nil
+			}
+
+			// Rich results go to stdout, or to --output-file, unless
+			// they are to be encoded
+			if parts, ok, err := resultParts(result); ok && !format.structured() {
+				if err != nil {
+					return err
+				}
+				outputFile, _ := cmd.Flags().GetString("output-file")
+				return writeCLIContent(cmd, parts, outputFile)
+			}
 
-			// Output result
-			output := formatCLIOutput(result)
-			fmt.Fprintln(cmd.OutOrStdout(), output)
+			out := cmd.OutOrStdout()
+			styled := isTerminal(out)
+			output, err := format.render(result, opt.outputColumns(), cmp.Or(sc.theme, DefaultTheme()), styled)
+			if err != nil {
+				return err
+			}
+			if styled {
+				// Adapts colors to what the terminal supports
+				lipgloss.Fprintln(out, output)
+				return nil
+			}
+			fmt.Fprintln(out, output)
 			return nil
 		},
 	}
 
 	// Add flags for each field
 	for _, f := range opt.fields {
-		fKey := f.key
-		if fKey == "" {
-			fKey = toSnakeCase(f.title)
-		}
-
-		flagName := toKebabCase(fKey)
+		flagName := toKebabCase(f.fieldKey())
 		flagDesc := f.title
 		if f.description != "" {
 			flagDesc = f.description
 		}
+		if len(f.enum) > 0 {
+			flagDesc += fmt.Sprintf(" (one of: %s)", strings.Join(f.enum, ", "))
+		}
 		if f.required {
 			flagDesc += " (required)"
 		}
 
-		val := new(string)
-		flagValues[fKey] = val
-		cmd.Flags().StringVar(val, flagName, "", flagDesc)
+		switch f.kind {
+		case KindInteger:
+			cmd.Flags().Int64(flagName, 0, flagDesc)
+		case KindNumber:
+			cmd.Flags().Float64(flagName, 0, flagDesc)
+		case KindBoolean:
+			cmd.Flags().Bool(flagName, false, flagDesc)
+		default:
+			cmd.Flags().String(flagName, "", flagDesc)
+		}
 
-		if f.required {
-			cmd.MarkFlagRequired(flagName)
+		if f.canSuggest() {
+			registerFlagCompletion(cmd, flagName, f, opt.fields)
 		}
 	}
 
-	return cmd
+	if opt.needsConfirm() {
+		cmd.Flags().BoolP("yes", "y", false, "Confirm this destructive action without prompting")
+	}
+	cmd.Flags().String("input", "", "Read arguments from a JSON or YAML object in this file, or stdin for -; flags take precedence")
+	cmd.Flags().String("output-file", "", "Write image and file results to this path (a directory if there are several)")
+
+	return cmd, nil
 }
 
 // toKebabCase converts a string to kebab-case for CLI flag/command names.
@@ -223,8 +537,10 @@ func formatCLIOutput(result any) string {
 }
 
 // RegisterCLI adds all generated subcommands to an existing Cobra command.
+// Persistent flags parent already has, such as a host -o, take precedence
+// over the generated ones.
 func (s *Select[T]) RegisterCLI(parent *cobra.Command) error {
-	cmds, err := s.ToSubcommands()
+	cmds, err := s.toSubcommands(parent)
 	if err != nil {
 		return err
 	}
@@ -238,3 +554,34 @@ func (s *Select[T]) RegisterCLI(parent *cobra.Command) error {
 func (s *Select[T]) CLI() (*cobra.Command, error) {
 	return s.ToCLI()
 }
+
+// writeCLIContent prints a rich result. Binary parts are written to
+// outputFile if given, or as raw bytes when a single binary result is piped;
+// otherwise they are summarized so the terminal is not flooded.
+func writeCLIContent(cmd *cobra.Command, parts []*part, outputFile string) error {
+	out := cmd.OutOrStdout()
+
+	if outputFile != "" {
+		written, err := writeParts(parts, outputFile)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", outputFile, err)
+		}
+		for _, p := range parts {
+			if !p.binary() {
+				fmt.Fprintln(out, renderParts([]*part{p}))
+			}
+		}
+		for _, path := range written {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Wrote %s\n", path)
+		}
+		return nil
+	}
+
+	if len(parts) == 1 && parts[0].binary() && !isTerminal(out) {
+		_, err := out.Write(parts[0].data)
+		return err
+	}
+
+	fmt.Fprintln(out, renderParts(parts))
+	return nil
+}
@@ -0,0 +1,611 @@
+package yeahno
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestRegisterCLISubmenu(t *testing.T) {
+	var root, siteChoice string
+
+	sites := NewSelect[string]().
+		Title("Sites").
+		Options(
+			NewOption("Add", "add").
+				WithField(NewInput().Key("domain").Title("Domain")).
+				WithField(NewInput().Key("depth").Title("Depth").Integer().Required(false)).
+				MCP(true),
+		).
+		Value(&siteChoice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return action + " " + fields["domain"] + " " + fields["depth"], nil
+		})
+
+	menu := NewSelect[string]().
+		Title("Admin").
+		Options(NewOption("Site", "site").Submenu(sites).MCP(true)).
+		Value(&root)
+
+	cmd, err := menu.ToCLI()
+	if err != nil {
+		t.Fatalf("ToCLI: %v", err)
+	}
+
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"site", "add", "--domain", "example.com", "--depth", "3"})
+	if err := cmd.ExecuteContext(context.Background()); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "add example.com 3" {
+		t.Fatalf("output = %q, want %q", got, "add example.com 3")
+	}
+
+	cmd.SetArgs([]string{"site", "add", "--domain", "example.com", "--depth", "deep"})
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.ExecuteContext(context.Background()); err == nil {
+		t.Fatal("expected error for non-integer --depth")
+	}
+}
+
+func TestRegisterCLIDestructive(t *testing.T) {
+	var choice string
+	calls := 0
+
+	menu := NewSelect[string]().
+		Title("Sites").
+		Options(NewOption("Purge", "purge").Destructive(true).MCP(true)).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			calls++
+			return "purged", nil
+		})
+
+	cmd, err := menu.ToCLI()
+	if err != nil {
+		t.Fatalf("ToCLI: %v", err)
+	}
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	// Not a terminal, so --yes is mandatory
+	cmd.SetIn(strings.NewReader(""))
+
+	cmd.SetArgs([]string{"purge"})
+	if err := cmd.ExecuteContext(context.Background()); err == nil || !strings.Contains(err.Error(), "--yes") {
+		t.Fatalf("expected --yes error, got %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("handler called without confirmation")
+	}
+
+	cmd.SetArgs([]string{"purge", "--yes"})
+	if err := cmd.ExecuteContext(context.Background()); err != nil {
+		t.Fatalf("execute with --yes: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("calls = %d, want 1", calls)
+	}
+}
+
+func TestRegisterCLIEnabledWhen(t *testing.T) {
+	var choice string
+	calls := 0
+
+	menu := NewSelect[string]().
+		Title("Sites").
+		Options(NewOption("Purge", "purge").
+			WithField(NewInput().Key("domain").Title("Domain")).
+			Destructive(true).
+			EnabledWhen(func(ctx context.Context) bool { return false }).
+			MCP(true)).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			calls++
+			return "purged", nil
+		})
+
+	cmd, err := menu.ToCLI()
+	if err != nil {
+		t.Fatalf("ToCLI: %v", err)
+	}
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetIn(strings.NewReader(""))
+
+	// Availability is reported before the missing flag or --yes
+	cmd.SetArgs([]string{"purge"})
+	if err := cmd.ExecuteContext(context.Background()); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("handler called for a disabled command")
+	}
+}
+
+func TestRegisterCLIMissingRequiredFlag(t *testing.T) {
+	var choice string
+	calls := 0
+
+	menu := NewSelect[string]().
+		Title("Sites").
+		Options(NewOption("Add", "add").
+			WithField(NewInput().Key("domain").Title("Domain").Required(true)).
+			MCP(true)).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			calls++
+			return "added " + fields["domain"], nil
+		})
+
+	cmd, err := menu.ToCLI()
+	if err != nil {
+		t.Fatalf("ToCLI: %v", err)
+	}
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	// Not a terminal, so missing flags are not prompted for
+	cmd.SetIn(strings.NewReader(""))
+
+	for _, args := range [][]string{{"add"}, {"add", "--no-input"}} {
+		cmd.SetArgs(args)
+		if err := cmd.ExecuteContext(context.Background()); err == nil || err.Error() != "required flag --domain not provided" {
+			t.Fatalf("%v: expected required flag error, got %v", args, err)
+		}
+	}
+	if calls != 0 {
+		t.Fatalf("handler called without required flag")
+	}
+
+	cmd.SetArgs([]string{"add", "--domain", "example.com", "--no-input"})
+	if err := cmd.ExecuteContext(context.Background()); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("calls = %d, want 1", calls)
+	}
+}
+
+func TestRegisterCLIInput(t *testing.T) {
+	var choice string
+	var got map[string]string
+
+	menu := NewSelect[string]().
+		Title("Sites").
+		Options(NewOption("Add", "add").
+			WithField(NewInput().Key("domain").Title("Domain").Required(true)).
+			WithField(NewInput().Key("depth").Title("Depth").Integer().Required(false)).
+			WithField(NewInput().Key("note").Title("Note").Required(false)).
+			MCP(true)).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			got = fields
+			return "ok", nil
+		})
+
+	run := func(stdin string, args ...string) error {
+		t.Helper()
+		cmd, err := menu.ToCLI()
+		if err != nil {
+			t.Fatalf("ToCLI: %v", err)
+		}
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&bytes.Buffer{})
+		cmd.SetIn(strings.NewReader(stdin))
+		cmd.SetArgs(append([]string{"add"}, args...))
+		return cmd.ExecuteContext(context.Background())
+	}
+
+	// JSON on stdin, with a flag taking precedence
+	if err := run(`{"domain":"example.com","depth":3,"note":"a \"quoted\" note"}`, "--input", "-", "--depth", "5"); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if got["domain"] != "example.com" || got["depth"] != "5" || got["note"] != `a "quoted" note` {
+		t.Fatalf("fields = %v", got)
+	}
+
+	// YAML from a file
+	path := filepath.Join(t.TempDir(), "args.yaml")
+	os.WriteFile(path, []byte("domain: a.io\ndepth: 2\n"), 0o644)
+	if err := run("", "--input", path); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if got["domain"] != "a.io" || got["depth"] != "2" {
+		t.Fatalf("fields = %v", got)
+	}
+
+	// Input values are validated like tool arguments
+	if err := run(`{"domain":"a.io","depth":"deep"}`, "--input", "-"); err == nil || !strings.Contains(err.Error(), "invalid depth") {
+		t.Fatalf("expected invalid depth error, got %v", err)
+	}
+}
+
+func TestRegisterCLIReservedFlag(t *testing.T) {
+	for _, key := range []string{"input", "output", "config", "no_input", "yes", "output_file"} {
+		var choice string
+		menu := NewSelect[string]().
+			Title("Sites").
+			Options(NewOption("Add", "add").
+				WithField(NewInput().Key(key).Title("Field")).
+				MCP(true)).
+			Value(&choice).
+			Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+				return "ok", nil
+			})
+
+		if _, err := menu.ToCLI(); err == nil || !strings.Contains(err.Error(), "built-in flag") {
+			t.Errorf("Expected built-in flag error for field %q, got: %v", key, err)
+		}
+	}
+}
+
+func TestRegisterCLIHostFlags(t *testing.T) {
+	var choice string
+	menu := NewSelect[string]().
+		Title("Sites").
+		Options(NewOption("List", "list").MCP(true)).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return map[string]string{"site": "example.com"}, nil
+		})
+
+	// The host already uses -o and --config for its own purposes
+	host := &cobra.Command{Use: "host"}
+	host.PersistentFlags().StringP("org", "o", "", "Organization")
+	host.PersistentFlags().String("config", "", "Host config")
+	if err := menu.RegisterCLI(host); err != nil {
+		t.Fatalf("RegisterCLI: %v", err)
+	}
+
+	var out bytes.Buffer
+	host.SetOut(&out)
+	host.SetArgs([]string{"list", "-o", "acme", "--output", "json"})
+	if err := host.ExecuteContext(context.Background()); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if got := strings.TrimSpace(out.String()); !strings.Contains(got, `"site": "example.com"`) {
+		t.Fatalf("output = %q, want JSON", got)
+	}
+	if org, _ := host.PersistentFlags().GetString("org"); org != "acme" {
+		t.Errorf("host -o = %q, want acme", org)
+	}
+}
+
+func TestRegisterCLIPositional(t *testing.T) {
+	var choice string
+	var got map[string]string
+
+	menu := NewSelect[string]().
+		Title("Tasks").
+		Options(NewOption("Note", "note").
+			WithField(NewInput().Key("id").Title("ID").Integer().Positional(0)).
+			WithField(NewInput().Key("text").Title("Text").Positional(1).Variadic()).
+			MCP(true)).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			got = fields
+			return "ok", nil
+		})
+
+	run := func(args ...string) error {
+		t.Helper()
+		cmd, err := menu.ToCLI()
+		if err != nil {
+			t.Fatalf("ToCLI: %v", err)
+		}
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&bytes.Buffer{})
+		cmd.SetIn(strings.NewReader(""))
+		cmd.SetArgs(append([]string{"note"}, args...))
+		return cmd.ExecuteContext(context.Background())
+	}
+
+	if err := run("123", "buy", "more", "milk"); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if got["id"] != "123" || got["text"] != "buy more milk" {
+		t.Fatalf("fields = %v", got)
+	}
+
+	// Flags still work in place of arguments
+	if err := run("--id", "7", "--text", "call back"); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if got["id"] != "7" || got["text"] != "call back" {
+		t.Fatalf("fields = %v", got)
+	}
+
+	if err := run("abc", "x"); err == nil || !strings.Contains(err.Error(), "invalid id") {
+		t.Fatalf("expected invalid id error, got %v", err)
+	}
+	if err := run("5"); err == nil || err.Error() != "required argument <text>... not provided" {
+		t.Fatalf("expected missing argument error, got %v", err)
+	}
+
+	cmd, _ := menu.ToCLI()
+	note, _, _ := cmd.Find([]string{"note"})
+	if note.Use != "note <id> <text>..." {
+		t.Fatalf("Use = %q", note.Use)
+	}
+}
+
+func TestRegisterCLIEnvAndConfig(t *testing.T) {
+	var choice string
+	var got map[string]string
+
+	config := filepath.Join(t.TempDir(), "sitectl.yaml")
+	os.WriteFile(config, []byte("region: us-east-1\ndomain: config.io\nadd:\n  depth: 3\n"), 0o644)
+	t.Setenv("SITECTL_DOMAIN", "env.io")
+	t.Setenv("SITE_TOKEN", "secret")
+
+	menu := NewSelect[string]().
+		Title("Sites").
+		EnvPrefix("SITECTL").
+		ConfigFile(config).
+		Options(NewOption("Add", "add").
+			WithField(NewInput().Key("domain").Title("Domain")).
+			WithField(NewInput().Key("token").Title("Token").Env("SITE_TOKEN")).
+			WithField(NewInput().Key("region").Title("Region")).
+			WithField(NewInput().Key("depth").Title("Depth").Integer()).
+			MCP(true)).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			got = fields
+			return "ok", nil
+		})
+
+	run := func(args ...string) error {
+		t.Helper()
+		cmd, err := menu.ToCLI()
+		if err != nil {
+			t.Fatalf("ToCLI: %v", err)
+		}
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&bytes.Buffer{})
+		cmd.SetIn(strings.NewReader(""))
+		cmd.SetArgs(append([]string{"add"}, args...))
+		return cmd.ExecuteContext(context.Background())
+	}
+
+	if err := run(); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	want := map[string]string{"domain": "env.io", "token": "secret", "region": "us-east-1", "depth": "3"}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("%s = %q, want %q (fields %v)", k, got[k], v, got)
+		}
+	}
+
+	// Flags win over the environment and config
+	if err := run("--domain", "flag.io", "--depth", "9"); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if got["domain"] != "flag.io" || got["depth"] != "9" {
+		t.Fatalf("fields = %v", got)
+	}
+
+	if err := run("--config", filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Fatal("expected error for a missing --config file")
+	}
+}
+
+func TestRegisterCLIOutputTable(t *testing.T) {
+	type site struct {
+		Domain string `json:"domain"`
+		Pages  int    `json:"pages"`
+	}
+	var choice string
+
+	menu := NewSelect[string]().
+		Title("Sites").
+		Options(NewOption("List", "list").Output([]site{}).MCP(true)).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return []site{{"example.com", 12}, {"a.io", 3}}, nil
+		})
+
+	cmd, err := menu.ToCLI()
+	if err != nil {
+		t.Fatalf("ToCLI: %v", err)
+	}
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"list"})
+	if err := cmd.ExecuteContext(context.Background()); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	want := "DOMAIN       PAGES\nexample.com  12\na.io         3"
+	if got := strings.TrimSpace(out.String()); got != want {
+		t.Fatalf("output = %q, want %q", got, want)
+	}
+}
+
+func TestRegisterCLIOutputFormats(t *testing.T) {
+	type site struct {
+		Domain string `json:"domain"`
+		Pages  int    `json:"pages"`
+	}
+	var choice string
+
+	menu := NewSelect[string]().
+		Title("Sites").
+		Options(NewOption("List", "list").MCP(true)).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return []site{{"example.com", 12}, {"a.io", 3}}, nil
+		})
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"json", "[\n  {\n    \"domain\": \"example.com\",\n    \"pages\": 12\n  },\n  {\n    \"domain\": \"a.io\",\n    \"pages\": 3\n  }\n]"},
+		{"yaml", "- domain: example.com\n  pages: 12\n- domain: a.io\n  pages: 3"},
+		{"table", "DOMAIN       PAGES\nexample.com  12\na.io         3"},
+		{"template={{range .}}{{.domain}} {{end}}", "example.com a.io"},
+	}
+	for _, tt := range tests {
+		cmd, err := menu.ToCLI()
+		if err != nil {
+			t.Fatalf("ToCLI: %v", err)
+		}
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetArgs([]string{"list", "-o", tt.format})
+		if err := cmd.ExecuteContext(context.Background()); err != nil {
+			t.Fatalf("execute -o %s: %v", tt.format, err)
+		}
+		if got := strings.TrimSpace(out.String()); got != tt.want {
+			t.Errorf("-o %s output = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+
+	cmd, _ := menu.ToCLI()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"list", "-o", "xml"})
+	if err := cmd.ExecuteContext(context.Background()); err == nil || !strings.Contains(err.Error(), "unknown output format") {
+		t.Fatalf("expected unknown output format error, got %v", err)
+	}
+}
+
+func TestRegisterCLIOutputFile(t *testing.T) {
+	var choice string
+
+	menu := NewSelect[string]().
+		Title("Report").
+		Options(NewOption("Export", "export").MCP(true)).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return Multi("exported", File("report.csv", strings.NewReader("a,b\n"))), nil
+		})
+
+	cmd, err := menu.ToCLI()
+	if err != nil {
+		t.Fatalf("ToCLI: %v", err)
+	}
+	dest := filepath.Join(t.TempDir(), "out.csv")
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"export", "--output-file", dest})
+	if err := cmd.ExecuteContext(context.Background()); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "exported" {
+		t.Fatalf("output = %q", got)
+	}
+	data, err := os.ReadFile(dest)
+	if err != nil || string(data) != "a,b\n" {
+		t.Fatalf("file = %q, %v", data, err)
+	}
+}
+
+func TestRegisterCLIProgress(t *testing.T) {
+	var choice string
+
+	menu := NewSelect[string]().
+		Title("Sites").
+		Options(NewOption("Crawl", "crawl").MCP(true)).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			Progress(ctx).Report(5, 10, "crawling")
+			return "crawled", nil
+		})
+
+	cmd, err := menu.ToCLI()
+	if err != nil {
+		t.Fatalf("ToCLI: %v", err)
+	}
+	var out, errOut bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&errOut)
+	cmd.SetArgs([]string{"crawl"})
+	if err := cmd.ExecuteContext(context.Background()); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "crawled" {
+		t.Fatalf("stdout = %q", got)
+	}
+	if got := strings.TrimSpace(errOut.String()); got != "50% (5/10) crawling" {
+		t.Fatalf("stderr = %q", got)
+	}
+}
+
+func TestRegisterCLIStream(t *testing.T) {
+	var choice string
+	var printed []string
+
+	var out bytes.Buffer
+	menu := NewSelect[string]().
+		Title("Logs").
+		Options(NewOption("Tail", "tail").MCP(true)).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return func(yield func(any) bool) {
+				for _, line := range []string{"line 1", "line 2"} {
+					yield(line)
+					// Each chunk is written before the next is produced
+					printed = append(printed, out.String())
+				}
+			}, nil
+		})
+
+	cmd, err := menu.ToCLI()
+	if err != nil {
+		t.Fatalf("ToCLI: %v", err)
+	}
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"tail"})
+	if err := cmd.ExecuteContext(context.Background()); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if out.String() != "line 1\nline 2\n" {
+		t.Fatalf("output = %q", out.String())
+	}
+	if printed[0] != "line 1\n" {
+		t.Fatalf("first chunk not written immediately: %q", printed[0])
+	}
+}
+
+func TestRegisterCLIFlagCompletion(t *testing.T) {
+	var choice string
+
+	menu := NewSelect[string]().
+		Title("Sites").
+		Options(
+			NewOption("Show", "show").
+				WithField(NewInput().Key("region").Title("Region").Required(false)).
+				WithField(NewInput().Key("domain").Title("Domain").
+					Suggest(func(ctx context.Context, partial string, fields map[string]string) []string {
+						return []string{fields["region"] + "." + partial + "example.com"}
+					})).
+				MCP(true),
+		).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return action, nil
+		})
+
+	cmd, err := menu.ToCLI()
+	if err != nil {
+		t.Fatalf("ToCLI: %v", err)
+	}
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"__complete", "show", "--region", "eu", "--domain", "www."})
+	if err := cmd.ExecuteContext(context.Background()); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if got := strings.Split(out.String(), "\n")[0]; got != "eu.www.example.com" {
+		t.Fatalf("completion = %q", out.String())
+	}
+}
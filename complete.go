@@ -0,0 +1,120 @@
+package yeahno
+
+import (
+	"context"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+)
+
+// maxCompletions is the most values an MCP completion response may carry.
+const maxCompletions = 100
+
+// SuggestFunc returns candidate values for a field. partial is what has
+// been typed so far and fields holds the values of other fields already
+// known.
+type SuggestFunc func(ctx context.Context, partial string, fields map[string]string) []string
+
+// Suggest sets a source of completions for the field. It feeds MCP
+// completion requests for prompt and resource template arguments, Cobra
+// shell completion for the field's flag and suggestions in the TUI prompt.
+// Enum fields complete from their values without it.
+func (i *Input) Suggest(fn SuggestFunc) *Input {
+	i.suggest = fn
+	return i
+}
+
+// suggestions returns the field's completions for partial.
+func (i *Input) suggestions(ctx context.Context, partial string, fields map[string]string) []string {
+	if i.suggest != nil {
+		return i.suggest(ctx, partial, fields)
+	}
+	var values []string
+	for _, v := range i.enum {
+		if strings.HasPrefix(v, partial) {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// canSuggest reports whether the field has completions to offer.
+func (i *Input) canSuggest() bool {
+	return i.suggest != nil || len(i.enum) > 0
+}
+
+// CompletionHandler answers MCP completion/complete requests for the
+// arguments of the Select's prompts and resource templates from each
+// field's Suggest callback. Pass it when creating the server:
+//
+//	server := mcp.NewServer(impl, &mcp.ServerOptions{
+//		CompletionHandler: menu.CompletionHandler(),
+//	})
+func (s *Select[T]) CompletionHandler() func(context.Context, *mcp.CompleteRequest) (*mcp.CompleteResult, error) {
+	return func(ctx context.Context, req *mcp.CompleteRequest) (*mcp.CompleteResult, error) {
+		result := &mcp.CompleteResult{Completion: mcp.CompletionResultDetails{Values: []string{}}}
+		ref := req.Params.Ref
+		if ref == nil {
+			return result, nil
+		}
+
+		var known map[string]string
+		if req.Params.Context != nil {
+			known = req.Params.Context.Arguments
+		}
+
+		inv := mcpInvocation(req.Session, req.Extra)
+		for _, t := range s.optionInfos(s.rootScope()) {
+			switch {
+			case ref.Type == "ref/prompt" && ref.Name == t.name:
+			case ref.Type == "ref/resource" && t.resourceURI != "" && ref.URI == t.resourceURI:
+			default:
+				continue
+			}
+			// Unavailable options complete nothing, as if they didn't exist
+			if !t.availableTo(ctx, *inv) {
+				return result, nil
+			}
+			for _, f := range t.fields {
+				if f.fieldKey() != req.Params.Argument.Name {
+					continue
+				}
+				values := f.suggestions(ctx, req.Params.Argument.Value, known)
+				result.Completion.Total = len(values)
+				if len(values) > maxCompletions {
+					values = values[:maxCompletions]
+					result.Completion.HasMore = true
+				}
+				if values != nil {
+					result.Completion.Values = values
+				}
+				return result, nil
+			}
+		}
+		return result, nil
+	}
+}
+
+// registerFlagCompletion wires a field's suggestions into Cobra shell
+// completion for its flag.
+func registerFlagCompletion(cmd *cobra.Command, flagName string, f *Input, fields []*Input) {
+	cmd.RegisterFlagCompletionFunc(flagName, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		known := make(map[string]string)
+		for _, other := range fields {
+			if flag := cmd.Flags().Lookup(toKebabCase(other.fieldKey())); flag != nil && flag.Changed {
+				known[other.fieldKey()] = flag.Value.String()
+			}
+		}
+		return f.suggestions(cmdContext(cmd), toComplete, known), cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+// cmdContext returns the command's context, which is nil during shell
+// completion unless the program set one.
+func cmdContext(cmd *cobra.Command) context.Context {
+	if ctx := cmd.Context(); ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
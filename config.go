@@ -0,0 +1,151 @@
+package yeahno
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Env reads the field from the environment variable name when it is not
+// given on the command line. It also pre-fills the TUI prompt.
+func (i *Input) Env(name string) *Input {
+	i.env = name
+	return i
+}
+
+// EnvPrefix binds every field without an explicit Env to PREFIX_KEY, e.g.
+// EnvPrefix("SITECTL") reads SITECTL_DOMAIN for the "domain" field.
+// Sub-menus inherit the prefix.
+func (s *Select[T]) EnvPrefix(prefix string) *Select[T] {
+	s.envPrefix = prefix
+	return s
+}
+
+// ConfigFile sets a YAML or JSON file of default field values for CLI
+// commands and TUI prompts. Top-level keys apply to every tool with a field
+// of that key; a section named after a tool applies to that tool only:
+//
+//	region: eu-west-1
+//	site_add:
+//	  depth: 3
+//
+// The --config flag overrides the path. A missing file is ignored unless
+// it was named with --config. Sub-menus inherit the file.
+func (s *Select[T]) ConfigFile(path string) *Select[T] {
+	s.configFile = path
+	return s
+}
+
+// envName returns the environment variable the field is read from, or "".
+func (i *Input) envName(prefix string) string {
+	if i.env != "" {
+		return i.env
+	}
+	if prefix == "" {
+		return ""
+	}
+	return prefix + "_" + strings.ToUpper(toSnakeCase(i.fieldKey()))
+}
+
+// fieldSource resolves field values that were not given explicitly: from
+// the environment first, then from the config file.
+type fieldSource struct {
+	envPrefix string
+	config    map[string]any
+	tool      string
+}
+
+// lookup returns the canonical value of f from the environment or config.
+func (src fieldSource) lookup(f *Input) (string, bool, error) {
+	key := f.fieldKey()
+	if name := f.envName(src.envPrefix); name != "" {
+		if v := os.Getenv(name); v != "" {
+			val, err := f.collect(v)
+			if err != nil {
+				return "", false, fmt.Errorf("%w (from $%s)", err, name)
+			}
+			return val, true, nil
+		}
+	}
+
+	v, ok := src.config[key]
+	if _, isSection := v.(map[string]any); isSection {
+		ok = false
+	}
+	if section, isSection := src.config[src.tool].(map[string]any); isSection {
+		if sv, found := section[key]; found {
+			v, ok = sv, true
+		}
+	}
+	if !ok || v == nil {
+		return "", false, nil
+	}
+	val, err := f.collect(v)
+	if err != nil {
+		return "", false, fmt.Errorf("%w (from config)", err)
+	}
+	return val, true, nil
+}
+
+// values resolves every field that has a value in the environment or config.
+func (src fieldSource) values(fields []*Input) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, f := range fields {
+		val, ok, err := src.lookup(f)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			values[f.fieldKey()] = val
+		}
+	}
+	return values, nil
+}
+
+// loadConfig reads a config file. A missing file yields no values unless
+// required is set.
+func loadConfig(path string, required bool) (map[string]any, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	config, err := decodeObject(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// decodeObject decodes a JSON or YAML object into the same form as tool
+// arguments, with numbers as json.Number.
+func decodeObject(data []byte) (map[string]any, error) {
+	// YAML is a superset of JSON, so one decoder handles both
+	var raw any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return map[string]any{}, nil
+	}
+	if _, ok := raw.(map[string]any); !ok {
+		return nil, fmt.Errorf("want an object")
+	}
+
+	// Round-trip through JSON so values decode exactly like tool arguments
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	return decodeArguments(data)
+}
@@ -0,0 +1,211 @@
+package yeahno
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/mhpenta/tap-go"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ErrNotConfirmed is returned when a confirmation prompt is declined.
+var ErrNotConfirmed = errors.New("action not confirmed")
+
+const (
+	// confirmArg is the MCP argument that confirms a destructive call when
+	// the client cannot elicit confirmation from the user.
+	confirmArg = "confirm"
+	// confirmTokenArg is the TAP argument that carries the confirmation token.
+	confirmTokenArg = "confirm_token"
+)
+
+// confirmSecret signs TAP confirmation tokens. Tokens are only valid for
+// the lifetime of the process.
+var confirmSecret = func() []byte {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}()
+
+func (o Option[T]) needsConfirm() bool {
+	return o.isDestructive() || o.confirmPrompt != ""
+}
+
+// confirmMessage renders the confirmation prompt, replacing {key}
+// placeholders with field values.
+func (o Option[T]) confirmMessage(fields map[string]string) string {
+	msg := o.confirmPrompt
+	if msg == "" {
+		msg = fmt.Sprintf("%s: this action is destructive. Continue?", o.Key)
+	}
+	for k, v := range fields {
+		msg = strings.ReplaceAll(msg, "{"+k+"}", v)
+	}
+	return msg
+}
+
+// promptConfirm asks the user a yes/no question in the terminal.
+func promptConfirm(msg string, theme *huh.Theme) (bool, error) {
+	var ok bool
+	confirm := huh.NewConfirm().
+		Title(msg).
+		Affirmative("Yes").
+		Negative("No").
+		Value(&ok)
+
+	form := huh.NewForm(huh.NewGroup(confirm))
+	if theme != nil {
+		form = form.WithTheme(theme)
+	}
+	if err := form.Run(); err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
+// isTerminal reports whether v (a reader or writer) is an interactive terminal.
+func isTerminal(v any) bool {
+	f, ok := v.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// supportsElicitation reports whether the MCP client can be asked for input.
+func supportsElicitation(ss *mcp.ServerSession) bool {
+	if ss == nil {
+		return false
+	}
+	params := ss.InitializeParams()
+	return params != nil && params.Capabilities != nil && params.Capabilities.Elicitation != nil
+}
+
+// confirmMCP gates a destructive MCP call. Clients that can elicit always
+// ask the user; others must send "confirm": true.
+func confirmMCP(ctx context.Context, req *mcp.CallToolRequest, input map[string]any, msg string) error {
+	if !supportsElicitation(req.Session) {
+		// Only the JSON boolean counts, not the string "true"
+		if input[confirmArg] == true {
+			return nil
+		}
+		return fmt.Errorf("%s Ask the user to confirm, then call again with %q: true", msg, confirmArg)
+	}
+
+	res, err := req.Session.Elicit(ctx, &mcp.ElicitParams{
+		Message: msg,
+		RequestedSchema: &jsonschema.Schema{
+			Type: "object",
+			Properties: map[string]*jsonschema.Schema{
+				confirmArg: {Type: "boolean", Description: "Confirm this action"},
+			},
+			Required: []string{confirmArg},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("confirmation failed: %v", err)
+	}
+	if res.Action != "accept" || res.Content[confirmArg] != true {
+		return ErrNotConfirmed
+	}
+	return nil
+}
+
+// hideConfirmArg removes the confirm argument from tools/list responses
+// for sessions that can elicit, since confirmMCP asks those users directly.
+func hideConfirmArg(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		res, err := next(ctx, method, req)
+		list, ok := res.(*mcp.ListToolsResult)
+		if err != nil || method != "tools/list" || !ok {
+			return res, err
+		}
+		ss, _ := req.GetSession().(*mcp.ServerSession)
+		if !supportsElicitation(ss) {
+			return res, nil
+		}
+
+		filtered := *list
+		filtered.Tools = make([]*mcp.Tool, len(list.Tools))
+		for i, t := range list.Tools {
+			filtered.Tools[i] = t
+			schema, ok := t.InputSchema.(map[string]any)
+			if !ok {
+				continue
+			}
+			props, ok := schema["properties"].(map[string]any)
+			if _, found := props[confirmArg]; !ok || !found {
+				continue
+			}
+			// Tools are shared across sessions, so copy before editing
+			props = maps.Clone(props)
+			delete(props, confirmArg)
+			schema = maps.Clone(schema)
+			schema["properties"] = props
+			tool := *t
+			tool.InputSchema = schema
+			filtered.Tools[i] = &tool
+		}
+		return &filtered, nil
+	}
+}
+
+// confirmToken derives the TAP confirmation token for a call. It is bound
+// to the tool and its exact arguments.
+func confirmToken(tool string, fields map[string]string) string {
+	mac := hmac.New(sha256.New, confirmSecret)
+	mac.Write([]byte(tool))
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		fmt.Fprintf(mac, "\x00%s=%s", k, fields[k])
+	}
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// confirmTAP gates a destructive TAP call. A first call without a valid
+// token fails with the token to resend; resending the same arguments with
+// that token runs the tool.
+//
+// The token goes back to the caller, so this only stops one-shot calls: a
+// client that resends it without asking anyone confirms itself. It gives
+// no human-in-the-loop guarantee; put destructive tools that need one
+// behind Require or your own approval step.
+func confirmTAP(tool string, input map[string]any, fields map[string]string, msg string) error {
+	want := confirmToken(tool, fields)
+	got, _ := input[confirmTokenArg].(string)
+	if hmac.Equal([]byte(got), []byte(want)) {
+		return nil
+	}
+	return tap.Errorf(tap.ErrInvalidRequest, "%s Resend the same arguments with %q: %q to confirm.", msg, confirmTokenArg, want)
+}
+
+// addSchemaProperty adds an extra property to a generated input schema.
+func addSchemaProperty(schema map[string]any, name string, prop map[string]any) {
+	props, ok := schema["properties"].(map[string]any)
+	if !ok {
+		props = make(map[string]any)
+		schema["properties"] = props
+	}
+	props[name] = prop
+}
@@ -0,0 +1,297 @@
+package yeahno
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Content is a handler result that is more than text: an image, a file, a
+// link to a resource, or several parts combined. Create one with Image,
+// File, ResourceLink or Multi and return it from a handler.
+type Content interface {
+	contentParts() ([]*part, error)
+}
+
+type partKind string
+
+const (
+	partText  partKind = "text"
+	partImage partKind = "image"
+	partFile  partKind = "resource"
+	partLink  partKind = "resource_link"
+)
+
+// part is a single piece of rich content.
+type part struct {
+	kind     partKind
+	text     string
+	name     string
+	mimeType string
+	uri      string
+	data     []byte
+	err      error
+}
+
+func (p *part) contentParts() ([]*part, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return []*part{p}, nil
+}
+
+// multi is a result made of several parts.
+type multi []any
+
+func (m multi) contentParts() ([]*part, error) {
+	var parts []*part
+	for _, v := range m {
+		switch x := v.(type) {
+		case nil:
+			continue
+		case Content:
+			ps, err := x.contentParts()
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, ps...)
+		default:
+			parts = append(parts, &part{kind: partText, text: resultToString(x)})
+		}
+	}
+	return parts, nil
+}
+
+// Image returns an image result. If mimeType is empty it is detected from
+// the data.
+func Image(data []byte, mimeType string) Content {
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	return &part{kind: partImage, data: data, mimeType: mimeType}
+}
+
+// File returns a file result read from r, such as a generated CSV or PDF.
+// The MIME type is derived from the name's extension. r is read
+// immediately and closed if it is an io.Closer.
+func File(name string, r io.Reader) Content {
+	data, err := io.ReadAll(r)
+	if c, ok := r.(io.Closer); ok {
+		c.Close()
+	}
+	if err != nil {
+		return &part{err: fmt.Errorf("failed to read %s: %w", name, err)}
+	}
+	mimeType := mime.TypeByExtension(filepath.Ext(name))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	return &part{kind: partFile, name: name, data: data, mimeType: mimeType}
+}
+
+// ResourceLink returns a link to a resource the caller can fetch itself.
+func ResourceLink(uri string) Content {
+	name := uri
+	if u, err := url.Parse(uri); err == nil && u.Path != "" {
+		name = path.Base(u.Path)
+	}
+	return &part{kind: partLink, uri: uri, name: name}
+}
+
+// Multi combines several results into one. Parts may be Content values,
+// strings or any JSON-marshalable value.
+func Multi(parts ...any) Content {
+	return multi(parts)
+}
+
+// resultParts returns the parts of a Content result. ok is false for
+// plain results.
+func resultParts(result any) (parts []*part, ok bool, err error) {
+	c, ok := result.(Content)
+	if !ok {
+		return nil, false, nil
+	}
+	parts, err = c.contentParts()
+	return parts, true, err
+}
+
+// isText reports whether the part's data is readable as text.
+func (p *part) isText() bool {
+	mt, _, _ := mime.ParseMediaType(p.mimeType)
+	return strings.HasPrefix(mt, "text/") ||
+		mt == "application/json" || mt == "application/xml" || strings.HasSuffix(mt, "+json")
+}
+
+// binary reports whether the part carries data rather than text or a link.
+func (p *part) binary() bool {
+	return p.kind == partImage || p.kind == partFile
+}
+
+// fileName returns the part's name, or one derived from its MIME type.
+func (p *part) fileName(i int) string {
+	if p.name != "" {
+		return p.name
+	}
+	ext := ""
+	if exts, _ := mime.ExtensionsByType(p.mimeType); len(exts) > 0 {
+		ext = exts[0]
+	}
+	return fmt.Sprintf("%s-%d%s", p.kind, i+1, ext)
+}
+
+// summary describes a binary part in one line.
+func (p *part) summary(i int) string {
+	return fmt.Sprintf("[%s: %s, %d bytes]", p.fileName(i), p.mimeType, len(p.data))
+}
+
+// mcpContent maps a part to its MCP content type.
+func (p *part) mcpContent() mcp.Content {
+	switch p.kind {
+	case partImage:
+		return &mcp.ImageContent{Data: p.data, MIMEType: p.mimeType}
+	case partFile:
+		rc := &mcp.ResourceContents{URI: "file:///" + p.name, MIMEType: p.mimeType}
+		if p.isText() {
+			rc.Text = string(p.data)
+		} else {
+			rc.Blob = p.data
+		}
+		return &mcp.EmbeddedResource{Resource: rc}
+	case partLink:
+		return &mcp.ResourceLink{URI: p.uri, Name: p.name}
+	default:
+		return &mcp.TextContent{Text: p.text}
+	}
+}
+
+// partJSON is the TAP wire form of a part. Binary data is base64 encoded.
+type partJSON struct {
+	Type     partKind `json:"type"`
+	Text     string   `json:"text,omitempty"`
+	Name     string   `json:"name,omitempty"`
+	MIMEType string   `json:"mimeType,omitempty"`
+	URI      string   `json:"uri,omitempty"`
+	Data     []byte   `json:"data,omitempty"`
+}
+
+func (p *part) MarshalJSON() ([]byte, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return json.Marshal(partJSON{
+		Type:     p.kind,
+		Text:     p.text,
+		Name:     p.name,
+		MIMEType: p.mimeType,
+		URI:      p.uri,
+		Data:     p.data,
+	})
+}
+
+func (m multi) MarshalJSON() ([]byte, error) {
+	parts, err := m.contentParts()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(parts)
+}
+
+// String renders text parts as-is, links as their URI and binary parts as
+// a one-line summary, so printing a Content result stays readable.
+func (p *part) String() string {
+	return renderParts([]*part{p})
+}
+
+func (m multi) String() string {
+	parts, err := m.contentParts()
+	if err != nil {
+		return err.Error()
+	}
+	return renderParts(parts)
+}
+
+func renderParts(parts []*part) string {
+	lines := make([]string, 0, len(parts))
+	for i, p := range parts {
+		switch {
+		case p.err != nil:
+			lines = append(lines, p.err.Error())
+		case p.kind == partText:
+			lines = append(lines, p.text)
+		case p.kind == partLink:
+			lines = append(lines, p.uri)
+		case p.kind == partFile && p.isText():
+			lines = append(lines, string(p.data))
+		default:
+			lines = append(lines, p.summary(i))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// writeParts writes binary parts to outputFile, or to outputFile/<name>
+// when there are several, and returns the paths written.
+func writeParts(parts []*part, outputFile string) ([]string, error) {
+	var binary []int
+	for i, p := range parts {
+		if p.binary() {
+			binary = append(binary, i)
+		}
+	}
+	if len(binary) > 1 {
+		if err := os.MkdirAll(outputFile, 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	var written []string
+	for _, i := range binary {
+		dest := outputFile
+		if len(binary) > 1 {
+			dest = filepath.Join(outputFile, filepath.Base(parts[i].fileName(i)))
+		}
+		if err := os.WriteFile(dest, parts[i].data, 0o644); err != nil {
+			return written, err
+		}
+		written = append(written, dest)
+	}
+	return written, nil
+}
+
+// saveParts offers to save each binary part of a TUI result to disk.
+func saveParts(parts []*part, theme *huh.Theme) error {
+	for i, p := range parts {
+		if !p.binary() {
+			continue
+		}
+		dest := p.fileName(i)
+		input := huh.NewInput().
+			Title(fmt.Sprintf("Save %s", p.summary(i))).
+			Description("Leave empty to skip").
+			Value(&dest)
+		form := huh.NewForm(huh.NewGroup(input))
+		if theme != nil {
+			form = form.WithTheme(theme)
+		}
+		if err := form.Run(); err != nil {
+			return err
+		}
+		if dest = strings.TrimSpace(dest); dest == "" {
+			continue
+		}
+		if err := os.WriteFile(dest, p.data, 0o644); err != nil {
+			return fmt.Errorf("failed to save %s: %w", dest, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package yeahno
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// maxElicitRounds bounds how often the user is re-asked for values that
+// still fail validation.
+const maxElicitRounds = 3
+
+// elicitFormats are the string formats MCP elicitation schemas allow.
+var elicitFormats = map[string]bool{"email": true, "uri": true, "date": true, "date-time": true}
+
+// fieldProblem is a field that is missing or invalid in the tool arguments.
+type fieldProblem struct {
+	field  *Input
+	reason string
+}
+
+// fieldProblems returns the fields a call cannot proceed without.
+func fieldProblems(fields []*Input, input map[string]any) []fieldProblem {
+	var problems []fieldProblem
+	for _, f := range fields {
+		raw, ok := input[f.fieldKey()]
+		if !ok || raw == nil {
+			if f.required {
+				problems = append(problems, fieldProblem{f, "required"})
+			}
+			continue
+		}
+		if _, err := f.collect(raw); err != nil {
+			problems = append(problems, fieldProblem{f, err.Error()})
+		}
+	}
+	return problems
+}
+
+// elicitFields asks the user of an MCP client for fields that are missing
+// or invalid, validates the answers like the TUI prompts do and merges them
+// into input. It is the MCP counterpart of the prompt loop in Select.Run.
+func elicitFields(ctx context.Context, ss *mcp.ServerSession, tool string, fields []*Input, input map[string]any) error {
+	for range maxElicitRounds {
+		problems := fieldProblems(fields, input)
+		if len(problems) == 0 {
+			return nil
+		}
+
+		var lines []string
+		schema := &jsonschema.Schema{
+			Type:       "object",
+			Properties: make(map[string]*jsonschema.Schema),
+		}
+		for _, p := range problems {
+			key := p.field.fieldKey()
+			schema.Properties[key] = p.field.elicitSchema()
+			schema.PropertyOrder = append(schema.PropertyOrder, key)
+			if p.field.required {
+				schema.Required = append(schema.Required, key)
+			}
+			lines = append(lines, fmt.Sprintf("- %s: %s", key, p.reason))
+		}
+
+		res, err := ss.Elicit(ctx, &mcp.ElicitParams{
+			Message:         fmt.Sprintf("%s needs more information:\n%s", tool, strings.Join(lines, "\n")),
+			RequestedSchema: schema,
+		})
+		if err != nil {
+			return fmt.Errorf("elicitation failed: %v", err)
+		}
+		if res.Action != "accept" {
+			return fmt.Errorf("user did not provide %s", problemKeys(problems))
+		}
+
+		for _, p := range problems {
+			key := p.field.fieldKey()
+			raw, ok := res.Content[key]
+			if !ok || raw == nil {
+				continue
+			}
+			val, err := p.field.coerce(raw)
+			if err == nil {
+				err = p.field.buildValidator()(val)
+			}
+			if err != nil {
+				// Keep the answer so the next round reports why it failed
+				input[key] = raw
+				continue
+			}
+			input[key] = val
+		}
+	}
+
+	if problems := fieldProblems(fields, input); len(problems) > 0 {
+		return fmt.Errorf("invalid values for %s after %d attempts", problemKeys(problems), maxElicitRounds)
+	}
+	return nil
+}
+
+// elicitSchema returns the field's schema restricted to what elicitation
+// supports, with its title and description for display.
+func (i *Input) elicitSchema() *jsonschema.Schema {
+	schema := i.jsonSchema()
+	schema.Title = i.title
+	schema.Description = i.description
+	if schema.Description == "" && i.placeholder != "" {
+		schema.Description = "e.g. " + i.placeholder
+	}
+	if !elicitFormats[schema.Format] {
+		schema.Format = ""
+	}
+	return schema
+}
+
+func problemKeys(problems []fieldProblem) string {
+	keys := make([]string, len(problems))
+	for i, p := range problems {
+		keys[i] = p.field.fieldKey()
+	}
+	return strings.Join(keys, ", ")
+}
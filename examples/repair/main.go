@@ -74,6 +74,9 @@ func buildMenu() *yeahno.Select[string] {
 			yeahno.NewOption("List tasks", "list").
 				ToolName("list-tasks").
 				Description("Show all tasks").
+				Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+					return []string{"Buy milk", "Fix bug", "Write docs"}, nil
+				}).
 				MCP(true),
 
 			yeahno.NewOption("Add task", "add").
@@ -81,12 +84,18 @@ func buildMenu() *yeahno.Select[string] {
 				Description("Create a new task").
 				WithField(yeahno.NewInput().Key("title").Title("Task title")).
 				WithField(yeahno.NewInput().Key("priority").Title("Priority").Required(false)).
+				Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+					return fmt.Sprintf("Added: %s", fields["title"]), nil
+				}).
 				MCP(true),
 
 			yeahno.NewOption("Complete task", "complete").
 				ToolName("complete-task").
 				Description("Mark task as done").
 				WithField(yeahno.NewInput().Key("id").Title("Task ID")).
+				Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+					return fmt.Sprintf("Completed task %s", fields["id"]), nil
+				}).
 				MCP(true),
 
 			yeahno.NewOption("Settings", "settings").
@@ -95,14 +104,9 @@ func buildMenu() *yeahno.Select[string] {
 			yeahno.NewOption("Exit", "exit"),
 		).
 		Value(&choice).
+		// Fallback for options without their own handler
 		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
 			switch action {
-			case "list":
-				return []string{"Buy milk", "Fix bug", "Write docs"}, nil
-			case "add":
-				return fmt.Sprintf("Added: %s", fields["title"]), nil
-			case "complete":
-				return fmt.Sprintf("Completed task %s", fields["id"]), nil
 			case "settings":
 				return "Opening settings...", nil
 			case "exit":
@@ -0,0 +1,192 @@
+package yeahno
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// FieldKind is the value type of an Input field.
+type FieldKind int
+
+const (
+	KindString FieldKind = iota
+	KindInteger
+	KindNumber
+	KindBoolean
+)
+
+// schemaType returns the JSON Schema type name for the kind.
+func (k FieldKind) schemaType() string {
+	switch k {
+	case KindInteger:
+		return "integer"
+	case KindNumber:
+		return "number"
+	case KindBoolean:
+		return "boolean"
+	default:
+		return "string"
+	}
+}
+
+// fieldKey returns the key the field is exposed under, falling back to the
+// snake_cased title.
+func (i *Input) fieldKey() string {
+	if i.key != "" {
+		return i.key
+	}
+	return toSnakeCase(i.title)
+}
+
+var errIntegerRange = fmt.Errorf("must be between %d and %d", int64(math.MinInt64), int64(math.MaxInt64))
+
+// checkEnums rejects enums on fields that aren't strings.
+func checkEnums(fields []*Input) error {
+	for _, f := range fields {
+		if len(f.enum) > 0 && f.kind != KindString {
+			return fmt.Errorf("field %s: Enum is only supported on string fields", f.fieldKey())
+		}
+	}
+	return nil
+}
+
+// parseValue checks s against the field's kind and enum and returns it in
+// canonical form ("5", "2.5", "true").
+func (i *Input) parseValue(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	switch i.kind {
+	case KindInteger:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err == nil {
+			return strconv.FormatInt(n, 10), nil
+		}
+		if errors.Is(err, strconv.ErrRange) {
+			return "", errIntegerRange
+		}
+		// Accept whole floats such as "5.0"
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || f != math.Trunc(f) || math.IsInf(f, 0) {
+			return "", fmt.Errorf("must be an integer")
+		}
+		// float64(MaxInt64) rounds up to 2^63, which no longer fits
+		if f < math.MinInt64 || f >= math.MaxInt64 {
+			return "", errIntegerRange
+		}
+		return strconv.FormatInt(int64(f), 10), nil
+	case KindNumber:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return "", fmt.Errorf("must be a number")
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case KindBoolean:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return "", fmt.Errorf("must be true or false")
+		}
+		return strconv.FormatBool(b), nil
+	}
+	if len(i.enum) > 0 && !slices.Contains(i.enum, s) {
+		return "", fmt.Errorf("must be one of: %s", strings.Join(i.enum, ", "))
+	}
+	return s, nil
+}
+
+// coerce converts a decoded JSON argument into the field's canonical string
+// form. Numbers sent as strings (and vice versa) are accepted.
+func (i *Input) coerce(v any) (string, error) {
+	var s string
+	switch x := v.(type) {
+	case string:
+		if i.kind == KindString && len(i.enum) == 0 {
+			return x, nil
+		}
+		s = x
+	case json.Number:
+		s = x.String()
+	case float64:
+		s = strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		s = strconv.FormatBool(x)
+	default:
+		return "", fmt.Errorf("must be a %s", i.kind.schemaType())
+	}
+	return i.parseValue(s)
+}
+
+// collectFields validates decoded tool arguments against the option's
+// fields and returns the canonical string values keyed by field key.
+func collectFields(fields []*Input, input map[string]any) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, f := range fields {
+		fKey := f.fieldKey()
+
+		raw, ok := input[fKey]
+		if !ok || raw == nil {
+			if f.required {
+				return nil, fmt.Errorf("missing required field: %s", fKey)
+			}
+			continue
+		}
+
+		val, err := f.collect(raw)
+		if err != nil {
+			return nil, err
+		}
+		values[fKey] = val
+	}
+	return values, nil
+}
+
+// collect validates a single decoded argument and returns its canonical
+// string value.
+func (i *Input) collect(raw any) (string, error) {
+	fKey := i.fieldKey()
+
+	val, err := i.coerce(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s: %v", fKey, err)
+	}
+
+	limit := maxFieldLength
+	if i.charLimit > 0 {
+		limit = i.charLimit
+	}
+	if len(val) > limit {
+		return "", fmt.Errorf("%s exceeds maximum length of %d", fKey, limit)
+	}
+	if i.format != "" {
+		if err := ValidateFormat(i.format, val); err != nil {
+			return "", fmt.Errorf("invalid %s: %v", fKey, err)
+		}
+	}
+	if i.validate != nil {
+		if err := i.validate(val); err != nil {
+			return "", fmt.Errorf("invalid %s: %v", fKey, err)
+		}
+	}
+	return val, nil
+}
+
+// IntField returns the value of an integer field, or 0 if it was not set.
+func IntField(fields map[string]string, key string) int {
+	n, _ := strconv.Atoi(fields[key])
+	return n
+}
+
+// NumberField returns the value of a number field, or 0 if it was not set.
+func NumberField(fields map[string]string, key string) float64 {
+	f, _ := strconv.ParseFloat(fields[key], 64)
+	return f
+}
+
+// BoolField returns the value of a boolean field, or false if it was not set.
+func BoolField(fields map[string]string, key string) bool {
+	b, _ := strconv.ParseBool(fields[key])
+	return b
+}
@@ -1,168 +1,357 @@
 package yeahno
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"mime"
 	"net/http"
+	"slices"
+	"strings"
 
+	"github.com/mhpenta/tap-go"
 	"github.com/mhpenta/tap-go/server"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 type httpTool struct {
 	name        string
 	description string
 	parameters  map[string]any
-	handler     func(ctx context.Context, args json.RawMessage) (any, error)
+	annotations *mcp.ToolAnnotations
+	output      map[string]any
+	// outputWrapped marks list outputs whose schema wraps them in "items"
+	outputWrapped bool
+	handler       func(ctx context.Context, args json.RawMessage) (any, error)
+	stream        tap.StreamHandler
 }
 
 func (s *Select[T]) toHTTPTools() ([]httpTool, error) {
-	if s.handler == nil {
-		return nil, fmt.Errorf("no handler configured")
-	}
+	return s.httpTools(s.rootScope())
+}
 
-	var opts []Option[T]
-	for _, o := range s.options {
-		if o.mcp {
-			opts = append(opts, o)
-		}
-	}
-	if len(opts) == 0 {
-		opts = s.options
+func (s *Select[T]) httpTools(sc scope) ([]httpTool, error) {
+	opts := s.exposedOptions()
+	if err := s.checkHandlers(opts); err != nil {
+		return nil, err
 	}
 
 	var tools []httpTool
 	for _, opt := range opts {
-		toolName := opt.toolName
-		if toolName == "" {
-			toolName = opt.Key
-		}
-		toolName = toSnakeCase(toolName)
-		if s.toolPrefix != "" {
-			toolName = s.toolPrefix + "_" + toolName
+		if opt.submenu != nil {
+			nested, err := opt.submenu.httpTools(s.childScope(sc, opt))
+			if err != nil {
+				return nil, err
+			}
+			tools = append(tools, nested...)
+			continue
 		}
 
+		toolName := joinToolName(sc.prefix, opt.name())
+
 		desc := opt.desc
 		if desc == "" {
 			desc = opt.Key
 		}
 
-		properties := make(map[string]any)
-		var required []string
-		var propertyOrder []string
-
-		for _, f := range opt.fields {
-			fKey := f.key
-			if fKey == "" {
-				fKey = toSnakeCase(f.title)
-			}
-
-			prop := map[string]any{"type": "string"}
-			if f.title != "" {
-				prop["description"] = f.title
-			}
-			if f.charLimit > 0 {
-				prop["maxLength"] = f.charLimit
-			}
-			if f.format != "" {
-				if fv, ok := formatValidators[f.format]; ok {
-					prop["format"] = fv.schemaFormat
-				}
-			}
-
-			properties[fKey] = prop
-			propertyOrder = append(propertyOrder, fKey)
-			if f.required {
-				required = append(required, fKey)
-			}
+		params, err := opt.schemaMap()
+		if err != nil {
+			return nil, fmt.Errorf("failed to build schema for tool %s: %w", toolName, err)
 		}
 
-		params := map[string]any{
-			"type":       "object",
-			"properties": properties,
+		if opt.needsConfirm() {
+			addSchemaProperty(params, confirmTokenArg, map[string]any{
+				"type":        "string",
+				"description": "Confirmation token returned by a previous call with the same arguments",
+			})
 		}
-		if len(required) > 0 {
-			params["required"] = required
+
+		var output map[string]any
+		if opt.outputSchema != nil {
+			output, err = opt.outputSchemaMap()
+			if err != nil {
+				return nil, fmt.Errorf("failed to build output schema for tool %s: %w", toolName, err)
+			}
 		}
 
 		opt := opt
-		handler := s.makeHTTPHandler(opt)
+		handler := s.makeHTTPHandler(sc, opt)
 
 		tools = append(tools, httpTool{
-			name:        toolName,
-			description: desc,
-			parameters:  params,
-			handler:     handler,
+			name:          toolName,
+			description:   desc,
+			parameters:    params,
+			annotations:   opt.toolAnnotations(),
+			output:        output,
+			outputWrapped: opt.outputWrapped,
+			handler:       handler,
+			stream:        s.makeHTTPStreamHandler(handler),
 		})
 	}
 
 	return tools, nil
 }
 
-func (s *Select[T]) makeHTTPHandler(opt Option[T]) func(ctx context.Context, args json.RawMessage) (any, error) {
+func (s *Select[T]) makeHTTPHandler(sc scope, opt Option[T]) func(ctx context.Context, args json.RawMessage) (any, error) {
 	return func(ctx context.Context, args json.RawMessage) (any, error) {
-		var input map[string]any
-		if err := json.Unmarshal(args, &input); err != nil {
+		tool := joinToolName(sc.prefix, opt.name())
+		inv := tapInvocation(ctx)
+		if err := checkCaller(ctx, *inv, tool, opt); err != nil {
+			return nil, tapUnavailable(err)
+		}
+
+		input, err := decodeArguments(args)
+		if err != nil {
 			return nil, fmt.Errorf("invalid arguments: %w", err)
 		}
 
-		fields := make(map[string]string)
-		for _, f := range opt.fields {
-			fKey := f.key
-			if fKey == "" {
-				fKey = toSnakeCase(f.title)
-			}
+		fields, err := collectFields(opt.fields, input)
+		if err != nil {
+			return nil, err
+		}
 
-			if val, ok := input[fKey].(string); ok {
-				limit := maxFieldLength
-				if f.charLimit > 0 {
-					limit = f.charLimit
-				}
-				if len(val) > limit {
-					return nil, fmt.Errorf("%s exceeds maximum length of %d", fKey, limit)
-				}
-				if f.format != "" {
-					if fv, ok := formatValidators[f.format]; ok {
-						if err := fv.validate(val); err != nil {
-							return nil, fmt.Errorf("invalid %s: %v", fKey, err)
-						}
-					}
-				}
-				if f.validate != nil {
-					if err := f.validate(val); err != nil {
-						return nil, fmt.Errorf("invalid %s: %v", fKey, err)
-					}
-				}
-				fields[fKey] = val
-			} else if f.required {
-				return nil, fmt.Errorf("missing required field: %s", fKey)
+		if opt.needsConfirm() {
+			if err := confirmTAP(tool, input, fields, opt.confirmMessage(fields)); err != nil {
+				return nil, err
 			}
 		}
 
-		return s.handler(ctx, opt.Value, fields)
+		ctx, stream := withStream(ctx, streamSink(ctx))
+		result, err := s.invoke(ctx, sc, inv, opt, fields)
+		if err != nil {
+			return nil, tapUnavailable(err)
+		}
+		// Without SSE, streamed chunks become the result
+		if streamSink(ctx) == nil {
+			result = stream.result(result)
+		}
+		// Surface unreadable files as a handler error rather than a
+		// failed encode
+		if _, _, err := resultParts(result); err != nil {
+			return nil, err
+		}
+		return result, nil
 	}
 }
 
+type tapStreamKey struct{}
+
+// streamSink returns the SSE chunk sender set up by the stream handler, or
+// nil for plain JSON requests.
+func streamSink(ctx context.Context) func(chunk any) error {
+	send, _ := ctx.Value(tapStreamKey{}).(func(chunk any) error)
+	return send
+}
+
+// makeHTTPStreamHandler serves the tool over SSE for clients that accept
+// text/event-stream, delivering progress reports and streamed chunks as
+// they happen. Each chunk is a result event; a non-nil handler result is
+// sent as the last one.
+func (s *Select[T]) makeHTTPStreamHandler(handler func(ctx context.Context, args json.RawMessage) (any, error)) tap.StreamHandler {
+	return func(ctx context.Context, args json.RawMessage, stream *tap.Stream) error {
+		ctx = withProgress(ctx, func(done, total float64, msg string) {
+			stream.Progress(progressFraction(done, total), msg)
+		})
+		ctx = context.WithValue(ctx, tapStreamKey{}, func(chunk any) error {
+			stream.Result(chunk)
+			return ctx.Err()
+		})
+		result, err := handler(ctx, args)
+		if err != nil {
+			return err
+		}
+		if result != nil {
+			stream.Result(result)
+		}
+		return nil
+	}
+}
+
+// tapRoutes are the routes tap-go serves.
+var tapRoutes = []string{"GET /tools", "GET /tools/{name}", "POST /tools/{name}/run"}
+
+// RegisterTAP registers the TAP endpoints on mux. They follow runtime
+// option changes (Select.AddOptions, RemoveOption, SetEnabled); a change
+// that cannot be turned into tools leaves the endpoints as they were.
 func (s *Select[T]) RegisterTAP(mux *http.ServeMux) error {
-	tools, err := s.toHTTPTools()
+	return s.RegisterTAPWith(mux, nil)
+}
+
+// RegisterTAPWith registers the TAP endpoints on mux like RegisterTAP,
+// authenticating callers as configured by opts.
+func (s *Select[T]) RegisterTAPWith(mux *http.ServeMux, opts *TAPOptions) error {
+	h, err := s.tapHandler(opts)
 	if err != nil {
 		return err
 	}
 
+	routes := tapRoutes
+	if opts != nil && opts.OpenAPI {
+		routes = append(slices.Clone(routes), "GET "+openAPIPath)
+	}
+
+	live := &swapHandler{}
+	live.store(h)
+	for _, route := range routes {
+		mux.Handle(route, live)
+	}
+	s.onChange(&listener{fn: func() {
+		if h, err := s.tapHandler(opts); err == nil {
+			live.store(h)
+		}
+	}})
+	return nil
+}
+
+// tapHandler builds the TAP endpoints for the current options.
+func (s *Select[T]) tapHandler(opts *TAPOptions) (http.Handler, error) {
+	tools, err := s.toHTTPTools()
+	if err != nil {
+		return nil, err
+	}
+
 	srv := server.New(s.tapDescription())
+	docs := make(map[string]map[string]any)
 	for i := range tools {
 		t := tools[i]
 		srv.AddTool(&server.Tool{
-			Name:        t.name,
-			Description: t.description,
-			Parameters:  t.parameters,
-			Handler:     t.handler,
+			Name:          t.name,
+			Description:   t.description,
+			Parameters:    t.parameters,
+			Handler:       t.handler,
+			StreamHandler: t.stream,
 		})
+		extra := make(map[string]any)
+		if t.annotations != nil {
+			extra["annotations"] = t.annotations
+		}
+		if t.output != nil {
+			extra["outputSchema"] = t.output
+		}
+		if len(extra) > 0 {
+			docs[t.name] = extra
+		}
 	}
 
-	srv.Register(mux, nil)
+	mux := http.NewServeMux()
+	srv.Register(mux, func(next http.Handler) http.Handler {
+		return authenticateTAP(opts, captureRequest(s.filterTAPTools(tools, serveRawContent(extendDocs(next, docs)))))
+	})
+	if opts != nil && opts.OpenAPI {
+		mux.Handle("GET "+openAPIPath, authenticateTAP(opts, captureRequest(s.serveOpenAPI(tools))))
+	}
+	return mux, nil
+}
 
-	return nil
+// extendDocs adds yeahno-specific keys (behavior hints, output schema) to
+// GET /tools/{name} responses, which tap-go renders without them.
+func extendDocs(next http.Handler, docs map[string]map[string]any) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		extra, ok := docs[r.PathValue("name")]
+		if !ok || r.Method != http.MethodGet || strings.HasSuffix(r.URL.Path, "/run") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &responseRecorder{header: make(http.Header), status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		var doc map[string]any
+		if rec.status != http.StatusOK || json.Unmarshal(rec.body.Bytes(), &doc) != nil {
+			rec.flush(w)
+			return
+		}
+		for k, v := range extra {
+			doc[k] = v
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(doc)
+	})
+}
+
+// serveRawContent returns image and file results as their own content type
+// when the request's Accept header asks for it, e.g. "Accept: image/png".
+// Otherwise results stay JSON with base64-encoded data.
+func serveRawContent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept := r.Header.Get("Accept")
+		if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/run") || !wantsRawContent(accept) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &responseRecorder{header: make(http.Header), status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		var resp struct {
+			Result partJSON `json:"result"`
+		}
+		if rec.status != http.StatusOK || json.Unmarshal(rec.body.Bytes(), &resp) != nil ||
+			(resp.Result.Type != partImage && resp.Result.Type != partFile) ||
+			!acceptsMediaType(accept, resp.Result.MIMEType) {
+			rec.flush(w)
+			return
+		}
+
+		w.Header().Set("Content-Type", resp.Result.MIMEType)
+		if resp.Result.Name != "" {
+			w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": resp.Result.Name}))
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(resp.Result.Data)
+	})
+}
+
+// wantsRawContent reports whether an Accept header names something other
+// than JSON, SSE or a wildcard.
+func wantsRawContent(accept string) bool {
+	for _, v := range strings.Split(accept, ",") {
+		mt, _, _ := mime.ParseMediaType(strings.TrimSpace(v))
+		switch mt {
+		case "", "*/*", "application/json", "text/event-stream":
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsMediaType reports whether an Accept header matches mimeType,
+// including type/* ranges.
+func acceptsMediaType(accept, mimeType string) bool {
+	want, _, _ := mime.ParseMediaType(mimeType)
+	for _, v := range strings.Split(accept, ",") {
+		mt, _, _ := mime.ParseMediaType(strings.TrimSpace(v))
+		if mt == want || mt == "*/*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(mt, "/*"); ok && strings.HasPrefix(want, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// responseRecorder buffers a response so it can be rewritten.
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header         { return r.header }
+func (r *responseRecorder) Write(b []byte) (int, error) { return r.body.Write(b) }
+func (r *responseRecorder) WriteHeader(status int)      { r.status = status }
+
+func (r *responseRecorder) flush(w http.ResponseWriter) {
+	for k, v := range r.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(r.status)
+	w.Write(r.body.Bytes())
 }
 
 func (s *Select[T]) RegisterHTTP(mux *http.ServeMux) error {
@@ -2,8 +2,10 @@ package yeahno
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -119,3 +121,560 @@ func TestRegisterHTTPUsesTAPServer(t *testing.T) {
 		t.Fatalf("not found code = %q, want %q", notFoundBody.Code, "not_found")
 	}
 }
+
+func TestRegisterTAPInvocation(t *testing.T) {
+	var choice string
+	var inv *Invocation
+
+	menu := NewSelect[string]().
+		Title("Who").
+		Options(NewOption("Whoami", "whoami").MCP(true)).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			inv = InvocationFrom(ctx)
+			return "ok", nil
+		})
+
+	mux := http.NewServeMux()
+	if err := menu.RegisterTAP(mux); err != nil {
+		t.Fatalf("register tap: %v", err)
+	}
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/tools/whoami/run", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-Id", "abc123")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /tools/whoami/run: %v", err)
+	}
+	resp.Body.Close()
+
+	if inv == nil {
+		t.Fatal("expected invocation in handler context")
+	}
+	if inv.Surface != SurfaceTAP || inv.Tool != "whoami" {
+		t.Fatalf("invocation = %+v", inv)
+	}
+	if inv.Request == nil || inv.Header.Get("X-Request-Id") != "abc123" {
+		t.Fatalf("expected TAP request metadata, got %+v", inv.Request)
+	}
+}
+
+func TestRegisterTAPDestructiveConfirm(t *testing.T) {
+	var choice string
+	calls := 0
+
+	menu := NewSelect[string]().
+		Title("Sites").
+		Options(
+			NewOption("Delete", "delete").
+				WithField(NewInput().Key("domain").Title("Domain")).
+				Destructive(true).
+				MCP(true),
+		).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			calls++
+			return "deleted " + fields["domain"], nil
+		})
+
+	mux := http.NewServeMux()
+	if err := menu.RegisterTAP(mux); err != nil {
+		t.Fatalf("register tap: %v", err)
+	}
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	run := func(body string) (int, map[string]any) {
+		t.Helper()
+		resp, err := http.Post(ts.URL+"/tools/delete/run", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST /tools/delete/run: %v", err)
+		}
+		defer resp.Body.Close()
+		var out map[string]any
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	status, out := run(`{"domain":"example.com"}`)
+	if status != http.StatusBadRequest || calls != 0 {
+		t.Fatalf("unconfirmed status = %d, calls = %d", status, calls)
+	}
+	msg, _ := out["message"].(string)
+	token := confirmToken("delete", map[string]string{"domain": "example.com"})
+	if !strings.Contains(msg, token) {
+		t.Fatalf("error message %q does not carry token", msg)
+	}
+
+	// The token is bound to the arguments
+	status, _ = run(fmt.Sprintf(`{"domain":"other.com","confirm_token":%q}`, token))
+	if status != http.StatusBadRequest || calls != 0 {
+		t.Fatalf("mismatched token status = %d, calls = %d", status, calls)
+	}
+
+	status, out = run(fmt.Sprintf(`{"domain":"example.com","confirm_token":%q}`, token))
+	if status != http.StatusOK || out["result"] != "deleted example.com" {
+		t.Fatalf("confirmed status = %d, body = %v", status, out)
+	}
+}
+
+func TestRegisterTAPAnnotations(t *testing.T) {
+	var choice string
+
+	menu := NewSelect[string]().
+		Title("Sites").
+		Options(
+			NewOption("List", "list").ReadOnly(true).DisplayTitle("List Sites").MCP(true),
+			NewOption("Add", "add").MCP(true),
+		).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return action, nil
+		})
+
+	mux := http.NewServeMux()
+	if err := menu.RegisterTAP(mux); err != nil {
+		t.Fatalf("register tap: %v", err)
+	}
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	var doc struct {
+		Name        string `json:"name"`
+		Annotations *struct {
+			ReadOnlyHint bool   `json:"readOnlyHint"`
+			Title        string `json:"title"`
+		} `json:"annotations"`
+	}
+	resp, err := http.Get(ts.URL + "/tools/list")
+	if err != nil {
+		t.Fatalf("GET /tools/list: %v", err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatalf("decode doc: %v", err)
+	}
+	if doc.Name != "list" || doc.Annotations == nil || !doc.Annotations.ReadOnlyHint || doc.Annotations.Title != "List Sites" {
+		t.Fatalf("doc = %+v", doc)
+	}
+
+	resp2, err := http.Get(ts.URL + "/tools/add")
+	if err != nil {
+		t.Fatalf("GET /tools/add: %v", err)
+	}
+	defer resp2.Body.Close()
+	var plain map[string]any
+	json.NewDecoder(resp2.Body).Decode(&plain)
+	if _, ok := plain["annotations"]; ok {
+		t.Fatalf("unexpected annotations on plain tool: %v", plain)
+	}
+}
+
+func TestRegisterTAPRichContent(t *testing.T) {
+	var choice string
+	png := []byte("\x89PNG\r\n\x1a\n")
+
+	menu := NewSelect[string]().
+		Title("Report").
+		Options(NewOption("Chart", "chart").MCP(true)).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return Image(png, "image/png"), nil
+		})
+
+	mux := http.NewServeMux()
+	if err := menu.RegisterTAP(mux); err != nil {
+		t.Fatalf("register tap: %v", err)
+	}
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/tools/chart/run", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("POST run: %v", err)
+	}
+	var out struct {
+		Result struct {
+			Type     string `json:"type"`
+			MIMEType string `json:"mimeType"`
+			Data     string `json:"data"`
+		} `json:"result"`
+	}
+	json.NewDecoder(resp.Body).Decode(&out)
+	resp.Body.Close()
+	if out.Result.Type != "image" || out.Result.MIMEType != "image/png" || out.Result.Data != base64.StdEncoding.EncodeToString(png) {
+		t.Fatalf("json result = %+v", out.Result)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/tools/chart/run", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "image/*")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST run: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if ct := resp.Header.Get("Content-Type"); ct != "image/png" || string(body) != string(png) {
+		t.Fatalf("raw result = %q %q", ct, body)
+	}
+}
+
+func TestRegisterTAPProgress(t *testing.T) {
+	var choice string
+
+	menu := NewSelect[string]().
+		Title("Sites").
+		Options(NewOption("Crawl", "crawl").MCP(true)).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			Progress(ctx).Report(1, 4, "page 1")
+			return "crawled", nil
+		})
+
+	mux := http.NewServeMux()
+	if err := menu.RegisterTAP(mux); err != nil {
+		t.Fatalf("register tap: %v", err)
+	}
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/tools/crawl/run", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST run: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	want := []string{
+		"event: progress\ndata: {\"message\":\"page 1\",\"progress\":0.25}",
+		"event: result\ndata: \"crawled\"",
+		"event: done",
+	}
+	for _, w := range want {
+		if !strings.Contains(string(body), w) {
+			t.Fatalf("stream missing %q:\n%s", w, body)
+		}
+	}
+}
+
+func TestRegisterTAPStream(t *testing.T) {
+	var choice string
+
+	menu := NewSelect[string]().
+		Title("Logs").
+		Options(NewOption("Tail", "tail").MCP(true)).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			fmt.Fprintln(Stream(ctx), "line 1")
+			Stream(ctx).Send(map[string]int{"line": 2})
+			return nil, nil
+		})
+
+	mux := http.NewServeMux()
+	if err := menu.RegisterTAP(mux); err != nil {
+		t.Fatalf("register tap: %v", err)
+	}
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/tools/tail/run", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST run: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	want := "event: result\ndata: \"line 1\\n\"\n\nevent: result\ndata: {\"line\":2}\n\nevent: done"
+	if !strings.Contains(string(body), want) {
+		t.Fatalf("stream = %q, want %q", body, want)
+	}
+
+	// Plain JSON callers get the chunks as the result
+	resp, err = http.Post(ts.URL+"/tools/tail/run", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("POST run: %v", err)
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Result []any `json:"result"`
+	}
+	json.NewDecoder(resp.Body).Decode(&out)
+	if len(out.Result) != 2 || out.Result[0] != "line 1\n" {
+		t.Fatalf("result = %v", out.Result)
+	}
+}
+
+func TestRegisterTAPFollowsOptionChanges(t *testing.T) {
+	var choice string
+
+	menu := NewSelect[string]().
+		Title("Sites").
+		Options(NewOption("List", "list").MCP(true)).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return action, nil
+		})
+
+	mux := http.NewServeMux()
+	if err := menu.RegisterTAP(mux); err != nil {
+		t.Fatalf("register tap: %v", err)
+	}
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	index := func() string {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/tools")
+		if err != nil {
+			t.Fatalf("GET /tools: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	menu.AddOptions(NewOption("Show", "show").MCP(true))
+	if got := index(); !strings.Contains(got, "show:") {
+		t.Fatalf("index missing added tool:\n%s", got)
+	}
+
+	menu.SetEnabled("list", false)
+	if got := index(); strings.Contains(got, "list:") {
+		t.Fatalf("index still lists disabled tool:\n%s", got)
+	}
+	resp, err := http.Post(ts.URL+"/tools/list/run", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("POST run: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestRegisterTAPVisibleWhen(t *testing.T) {
+	var choice string
+
+	isOnCall := func(ctx context.Context) bool {
+		return InvocationFrom(ctx).Header.Get("X-Role") == "oncall"
+	}
+	menu := NewSelect[string]().
+		Title("Sites").
+		Options(
+			NewOption("List", "list").MCP(true),
+			NewOption("Purge", "purge").
+				Description("Purge the cache.\nWipes every edge node.").
+				MCP(true).
+				VisibleWhen(isOnCall),
+			NewOption("Restart", "restart").MCP(true).EnabledWhen(isOnCall),
+		).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return action, nil
+		})
+
+	mux := http.NewServeMux()
+	if err := menu.RegisterTAP(mux); err != nil {
+		t.Fatalf("register tap: %v", err)
+	}
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	do := func(method, path, role string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		if role != "" {
+			req.Header.Set("X-Role", role)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	// Continuation lines of a hidden tool's description must not leak
+	if _, body := do("GET", "/tools", ""); strings.Contains(body, "purge:") || strings.Contains(body, "Wipes") || !strings.Contains(body, "restart:") {
+		t.Fatalf("agent index should hide purge and list restart:\n%s", body)
+	}
+	if _, body := do("GET", "/tools", "oncall"); !strings.Contains(body, "purge: Purge the cache.\nWipes every edge node.") {
+		t.Fatalf("on-call index missing purge:\n%s", body)
+	}
+
+	if status, _ := do("GET", "/tools/purge", ""); status != http.StatusNotFound {
+		t.Fatalf("hidden docs status = %d, want 404", status)
+	}
+	if status, _ := do("POST", "/tools/purge/run", ""); status != http.StatusNotFound {
+		t.Fatalf("hidden run status = %d, want 404", status)
+	}
+	if status, body := do("POST", "/tools/restart/run", ""); status != http.StatusBadRequest || !strings.Contains(body, "not available") {
+		t.Fatalf("disabled run = %d %s, want 400 not available", status, body)
+	}
+	if status, body := do("POST", "/tools/purge/run", "oncall"); status != http.StatusOK || !strings.Contains(body, "purge") {
+		t.Fatalf("on-call run = %d %s", status, body)
+	}
+}
+
+func TestRegisterTAPWithAuth(t *testing.T) {
+	var choice string
+
+	menu := NewSelect[string]().
+		Title("Sites").
+		Options(
+			NewOption("List", "list").MCP(true),
+			NewOption("Purge", "purge").MCP(true).Require("admin"),
+		).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return action + " by " + InvocationFrom(ctx).Principal.Name, nil
+		})
+
+	mux := http.NewServeMux()
+	err := menu.RegisterTAPWith(mux, &TAPOptions{
+		OpenAPI: true,
+		Authenticate: AnyOf(
+			BearerToken(map[string]*Principal{"admin-token": {Name: "oncall", Roles: []string{"admin"}}}),
+			APIKey("X-API-Key", map[string]*Principal{"agent-key": {Name: "agent"}}),
+		),
+	})
+	if err != nil {
+		t.Fatalf("register tap: %v", err)
+	}
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	do := func(method, path string, header ...string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if status, body := do("GET", "/tools"); status != http.StatusUnauthorized || !strings.Contains(body, `"unauthorized"`) {
+		t.Fatalf("anonymous index = %d %s, want 401 unauthorized", status, body)
+	}
+	if status, _ := do("GET", "/tools", "Authorization", "Bearer wrong"); status != http.StatusUnauthorized {
+		t.Fatalf("bad token status = %d, want 401", status)
+	}
+
+	if _, body := do("GET", "/tools", "X-API-Key", "agent-key"); strings.Contains(body, "purge:") || !strings.Contains(body, "list:") {
+		t.Fatalf("agent index should only list list:\n%s", body)
+	}
+	if status, body := do("POST", "/tools/purge/run", "X-API-Key", "agent-key"); status != http.StatusForbidden || !strings.Contains(body, `"forbidden"`) {
+		t.Fatalf("agent purge = %d %s, want 403 forbidden", status, body)
+	}
+	if status, body := do("GET", "/openapi.json", "X-API-Key", "agent-key"); status != http.StatusOK || strings.Contains(body, "/tools/purge/run") || !strings.Contains(body, "/tools/list/run") {
+		t.Fatalf("agent OpenAPI document should only describe list: %d %s", status, body)
+	}
+
+	if _, body := do("GET", "/tools", "Authorization", "Bearer admin-token"); !strings.Contains(body, "purge:") {
+		t.Fatalf("admin index missing purge:\n%s", body)
+	}
+	if _, body := do("GET", "/openapi.json", "Authorization", "Bearer admin-token"); !strings.Contains(body, "/tools/purge/run") {
+		t.Fatalf("admin OpenAPI document missing purge:\n%s", body)
+	}
+	if status, body := do("POST", "/tools/purge/run", "Authorization", "Bearer admin-token"); status != http.StatusOK || !strings.Contains(body, "purge by oncall") {
+		t.Fatalf("admin purge = %d %s", status, body)
+	}
+}
+
+func TestRegisterTAPOpenAPI(t *testing.T) {
+	type site struct {
+		Domain string `json:"domain"`
+	}
+	var choice string
+
+	menu := NewSelect[string]().
+		Title("Sites").
+		Description("Manage sites").
+		Options(
+			NewOption("List", "list").Output([]site{}).MCP(true),
+			NewOption("Add", "add").
+				WithField(NewInput().Key("domain").Title("Domain").Required(true)).
+				MCP(true),
+		).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return action, nil
+		})
+
+	mux := http.NewServeMux()
+	if err := menu.RegisterTAPWith(mux, &TAPOptions{OpenAPI: true}); err != nil {
+		t.Fatalf("register tap: %v", err)
+	}
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/openapi.json")
+	if err != nil {
+		t.Fatalf("GET /openapi.json: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Info    struct {
+			Title string `json:"title"`
+		} `json:"info"`
+		Paths map[string]map[string]struct {
+			RequestBody struct {
+				Content map[string]struct {
+					Schema map[string]any `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+			Responses map[string]struct {
+				Content map[string]struct {
+					Schema struct {
+						Properties map[string]map[string]any `json:"properties"`
+					} `json:"schema"`
+				} `json:"content"`
+			} `json:"responses"`
+		} `json:"paths"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if doc.OpenAPI != "3.1.0" || doc.Info.Title != "Sites" {
+		t.Fatalf("openapi = %q, title = %q", doc.OpenAPI, doc.Info.Title)
+	}
+	for _, p := range []string{"/tools", "/tools/{name}", "/tools/list/run", "/tools/add/run"} {
+		if _, ok := doc.Paths[p]; !ok {
+			t.Fatalf("missing path %s", p)
+		}
+	}
+
+	add := doc.Paths["/tools/add/run"]["post"]
+	body := add.RequestBody.Content["application/json"].Schema
+	if _, ok := body["properties"].(map[string]any)["domain"]; !ok {
+		t.Fatalf("add request body missing domain: %v", body)
+	}
+
+	list := doc.Paths["/tools/list/run"]["post"]
+	result := list.Responses["200"].Content["application/json"].Schema.Properties["result"]
+	if _, ok := result["items"]; !ok {
+		t.Fatalf("list result schema = %v, want an unwrapped array", result)
+	}
+}
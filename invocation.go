@@ -0,0 +1,85 @@
+package yeahno
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Invocation describes who triggered a handler and through which surface.
+// Surface-specific fields are left zero on other surfaces.
+type Invocation struct {
+	Surface Surface
+	// Tool is the flattened tool name, identical on every surface.
+	Tool string
+	// Value is the selected option's value.
+	Value any
+
+	// Session is the MCP session the call arrived on.
+	Session *mcp.ServerSession
+	// SessionID is the MCP session ID, if the transport assigns one.
+	SessionID string
+	// Client is the name and version the MCP client reported at initialization.
+	Client *mcp.Implementation
+
+	// Request is the HTTP request of a TAP call.
+	Request *http.Request
+	// Header holds HTTP headers of TAP calls and MCP calls over HTTP transports.
+	Header http.Header
+	// Principal is the TAP caller accepted by TAPOptions.Authenticate.
+	Principal *Principal
+
+	// CommandPath is the full Cobra command path of a CLI call, e.g. "myapp site add".
+	CommandPath string
+}
+
+type invocationKey struct{}
+
+type httpRequestKey struct{}
+
+// InvocationFrom returns the invocation that triggered the current handler,
+// or nil if ctx was not created by yeahno.
+func InvocationFrom(ctx context.Context) *Invocation {
+	inv, _ := ctx.Value(invocationKey{}).(*Invocation)
+	return inv
+}
+
+func withInvocation(ctx context.Context, inv *Invocation) context.Context {
+	return context.WithValue(ctx, invocationKey{}, inv)
+}
+
+// mcpInvocation collects session and client details from an MCP request.
+func mcpInvocation(ss *mcp.ServerSession, extra *mcp.RequestExtra) *Invocation {
+	inv := &Invocation{Surface: SurfaceMCP}
+	if ss != nil {
+		inv.Session = ss
+		inv.SessionID = ss.ID()
+		if params := ss.InitializeParams(); params != nil {
+			inv.Client = params.ClientInfo
+		}
+	}
+	if extra != nil {
+		inv.Header = extra.Header
+	}
+	return inv
+}
+
+// tapInvocation collects HTTP request details stored by captureRequest.
+func tapInvocation(ctx context.Context) *Invocation {
+	inv := &Invocation{Surface: SurfaceTAP}
+	if r, ok := ctx.Value(httpRequestKey{}).(*http.Request); ok {
+		inv.Request = r
+		inv.Header = r.Header
+	}
+	inv.Principal, _ = ctx.Value(principalKey{}).(*Principal)
+	return inv
+}
+
+// captureRequest stores the incoming request in its context so TAP
+// handlers, which only receive the context, can report it.
+func captureRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), httpRequestKey{}, r)))
+	})
+}
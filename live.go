@@ -0,0 +1,216 @@
+package yeahno
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Disabled hides the option from every surface until it is enabled again
+// with Select.SetEnabled.
+func (o Option[T]) Disabled(disabled bool) Option[T] {
+	o.disabled = disabled
+	return o
+}
+
+// AddOptions adds options to the Select at runtime, replacing any option
+// with the same value. Bound MCP servers and registered TAP endpoints are
+// updated.
+func (s *Select[T]) AddOptions(options ...Option[T]) *Select[T] {
+	s.mu.Lock()
+	for _, opt := range options {
+		i := slices.IndexFunc(s.options, func(o Option[T]) bool { return o.Value == opt.Value })
+		if i >= 0 {
+			s.detach(s.options[i])
+			s.options[i] = opt
+		} else {
+			s.options = append(s.options, opt)
+		}
+		s.attach(opt)
+	}
+	s.mu.Unlock()
+	s.changed()
+	return s
+}
+
+// RemoveOption removes the option with the given value at runtime.
+func (s *Select[T]) RemoveOption(value T) *Select[T] {
+	s.mu.Lock()
+	s.options = slices.DeleteFunc(s.options, func(o Option[T]) bool {
+		if o.Value != value {
+			return false
+		}
+		s.detach(o)
+		return true
+	})
+	s.mu.Unlock()
+	s.changed()
+	return s
+}
+
+// SetEnabled enables or disables the option with the given value at
+// runtime. Disabled options are hidden from every surface.
+func (s *Select[T]) SetEnabled(value T, enabled bool) *Select[T] {
+	s.mu.Lock()
+	for i := range s.options {
+		if s.options[i].Value == value {
+			s.options[i].disabled = !enabled
+		}
+	}
+	s.mu.Unlock()
+	s.changed()
+	return s
+}
+
+// currentOptions returns a snapshot of the options, safe to use while
+// they are changed concurrently.
+func (s *Select[T]) currentOptions() []Option[T] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.options)
+}
+
+// listener is a change callback. It is passed by pointer so it can be
+// detached from a sub-menu again.
+type listener struct {
+	fn func()
+}
+
+// onChange registers l to be called whenever the options of this Select
+// or any of its sub-menus change.
+func (s *Select[T]) onChange(l *listener) {
+	s.mu.Lock()
+	s.listeners = append(s.listeners, l)
+	opts := slices.Clone(s.options)
+	s.mu.Unlock()
+
+	for _, o := range opts {
+		if o.submenu != nil {
+			o.submenu.onChange(l)
+		}
+	}
+}
+
+// offChange unregisters l from this Select and its sub-menus.
+func (s *Select[T]) offChange(l *listener) {
+	s.mu.Lock()
+	s.listeners = slices.DeleteFunc(s.listeners, func(x *listener) bool { return x == l })
+	opts := slices.Clone(s.options)
+	s.mu.Unlock()
+
+	for _, o := range opts {
+		if o.submenu != nil {
+			o.submenu.offChange(l)
+		}
+	}
+}
+
+// attach and detach register and unregister this Select's listeners on
+// the option's sub-menu. The caller holds s.mu.
+func (s *Select[T]) attach(opt Option[T]) {
+	if opt.submenu == nil {
+		return
+	}
+	for _, l := range s.listeners {
+		opt.submenu.onChange(l)
+	}
+}
+
+func (s *Select[T]) detach(opt Option[T]) {
+	if opt.submenu == nil {
+		return
+	}
+	for _, l := range s.listeners {
+		opt.submenu.offChange(l)
+	}
+}
+
+func (s *Select[T]) changed() {
+	s.mu.RLock()
+	listeners := slices.Clone(s.listeners)
+	s.mu.RUnlock()
+	for _, l := range listeners {
+		l.fn()
+	}
+}
+
+// installMiddleware adds the Select's MCP middleware to server once, however
+// many times its tools are registered or bound there.
+func (s *Select[T]) installMiddleware(server *mcp.Server) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.servers[server] {
+		return
+	}
+	if s.servers == nil {
+		s.servers = make(map[*mcp.Server]bool)
+	}
+	s.servers[server] = true
+	server.AddReceivingMiddleware(s.filterMCPLists, hideConfirmArg)
+	server.AddSendingMiddleware(markLogDelivered)
+}
+
+// Bind registers the Select's tools on an MCP server and keeps them in
+// sync: adding, removing, enabling or disabling options (here or in a
+// sub-menu) updates the server's tool list, which notifies clients with
+// notifications/tools/list_changed.
+//
+// A change that cannot be turned into tools, such as an option without a
+// handler, leaves the server's tools as they were.
+func (s *Select[T]) Bind(server *mcp.Server) error {
+	var mu sync.Mutex
+	var names []string
+
+	resync := func() error {
+		tools, err := s.ToTools()
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		current := make([]string, len(tools))
+		for i, td := range tools {
+			current[i] = td.Tool.Name
+		}
+		var stale []string
+		for _, name := range names {
+			if !slices.Contains(current, name) {
+				stale = append(stale, name)
+			}
+		}
+		if len(stale) > 0 {
+			server.RemoveTools(stale...)
+		}
+		for _, td := range tools {
+			server.AddTool(td.Tool, td.Handler)
+		}
+		names = current
+		return nil
+	}
+
+	if err := resync(); err != nil {
+		return err
+	}
+	s.installMiddleware(server)
+	s.onChange(&listener{fn: func() { resync() }})
+	return nil
+}
+
+// swapHandler serves requests with a handler that can be replaced while
+// serving, so TAP endpoints follow option changes.
+type swapHandler struct {
+	current atomic.Pointer[http.Handler]
+}
+
+func (h *swapHandler) store(next http.Handler) {
+	h.current.Store(&next)
+}
+
+func (h *swapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*h.current.Load()).ServeHTTP(w, r)
+}
@@ -0,0 +1,114 @@
+package yeahno
+
+import (
+	"cmp"
+	"context"
+	"slices"
+)
+
+// Surface identifies how a handler was invoked.
+type Surface string
+
+const (
+	SurfaceTUI Surface = "tui"
+	SurfaceCLI Surface = "cli"
+	SurfaceMCP Surface = "mcp"
+	SurfaceTAP Surface = "tap"
+)
+
+// Call describes a single handler invocation as seen by middleware.
+type Call struct {
+	Surface Surface
+	// Tool is the flattened tool name, identical on every surface.
+	Tool string
+	// Value is the selected option's value.
+	Value any
+	// Fields holds the validated field values. Middleware may modify it
+	// before calling next.
+	Fields map[string]string
+}
+
+// HandlerFunc is a handler invocation wrapped by middleware.
+type HandlerFunc func(ctx context.Context, call *Call) (any, error)
+
+// Middleware wraps every handler invocation on every surface. Use it for
+// logging, metrics, auth checks, timeouts and panic recovery.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Use appends middleware to the Select. Middleware runs in the order added
+// and also wraps handlers of nested sub-menus.
+func (s *Select[T]) Use(mw ...Middleware) *Select[T] {
+	s.middleware = append(s.middleware, mw...)
+	return s
+}
+
+// scope is how a menu was reached: the composed tool prefix and the
+// middleware, CLI theme and field sources inherited from parent menus.
+type scope struct {
+	prefix     string
+	middleware []Middleware
+	theme      *Theme
+	envPrefix  string
+	configFile string
+}
+
+// rootScope is the scope of a Select used directly rather than as a submenu.
+func (s *Select[T]) rootScope() scope {
+	return scope{
+		prefix:     s.toolPrefix,
+		theme:      s.cliTheme,
+		envPrefix:  s.envPrefix,
+		configFile: s.configFile,
+	}
+}
+
+// childScope returns the scope for an option's submenu.
+func (s *Select[T]) childScope(sc scope, opt Option[T]) scope {
+	return scope{
+		prefix:     submenuPrefix(sc.prefix, opt),
+		middleware: s.chain(sc),
+		theme:      cmp.Or(s.cliTheme, sc.theme),
+		envPrefix:  cmp.Or(s.envPrefix, sc.envPrefix),
+		configFile: cmp.Or(s.configFile, sc.configFile),
+	}
+}
+
+// chain returns the inherited middleware followed by the Select's own.
+func (s *Select[T]) chain(sc scope) []Middleware {
+	return append(slices.Clone(sc.middleware), s.middleware...)
+}
+
+// invoke calls the option's handler through the middleware chain. inv
+// carries the surface-specific details; invoke fills in the rest and makes
+// it available through InvocationFrom.
+func (s *Select[T]) invoke(ctx context.Context, sc scope, inv *Invocation, opt Option[T], fields map[string]string) (any, error) {
+	inv.Tool = joinToolName(sc.prefix, opt.name())
+	inv.Value = opt.Value
+	ctx = withInvocation(ctx, inv)
+
+	if err := opt.checkAvailable(ctx, inv.Tool); err != nil {
+		return nil, err
+	}
+
+	h := s.handlerFor(opt)
+	next := func(ctx context.Context, call *Call) (any, error) {
+		result, err := h(ctx, opt.Value, call.Fields)
+		if err != nil {
+			return nil, err
+		}
+		// Iterator results are streamed, so middleware sees the final result
+		return drainIterator(ctx, result)
+	}
+
+	chain := s.chain(sc)
+	for i := len(chain) - 1; i >= 0; i-- {
+		next = chain[i](next)
+	}
+
+	return next(ctx, &Call{
+		Surface: inv.Surface,
+		Tool:    inv.Tool,
+		Value:   opt.Value,
+		Fields:  fields,
+	})
+}
@@ -0,0 +1,212 @@
+package yeahno
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mhpenta/tap-go"
+)
+
+// openAPIVersion is the OpenAPI version of generated documents.
+const openAPIVersion = "3.1.0"
+
+// openAPIPath is where RegisterTAPWith serves the document when
+// TAPOptions.OpenAPI is set.
+const openAPIPath = "/openapi.json"
+
+// OpenAPI returns an OpenAPI 3.1 document describing the TAP endpoints:
+// the GET /tools index, GET /tools/{name} docs and one POST
+// /tools/{name}/run operation per tool, with request bodies from the
+// option fields, response schemas from Output and the TAP error format.
+// Marshal it with encoding/json; add servers or security schemes to the
+// map before publishing if your gateway needs them.
+func (s *Select[T]) OpenAPI() (map[string]any, error) {
+	tools, err := s.toHTTPTools()
+	if err != nil {
+		return nil, err
+	}
+	return s.openAPIDoc(tools), nil
+}
+
+// openAPIDoc builds the OpenAPI document for tools.
+func (s *Select[T]) openAPIDoc(tools []httpTool) map[string]any {
+	title := s.title
+	if title == "" {
+		title = "Tools"
+	}
+	info := map[string]any{"title": title, "version": "1.0.0"}
+	if s.description != "" {
+		info["description"] = s.description
+	}
+
+	nameSchema := map[string]any{"type": "string"}
+	if len(tools) > 0 {
+		names := make([]any, len(tools))
+		for i, t := range tools {
+			names[i] = t.name
+		}
+		nameSchema["enum"] = names
+	}
+
+	paths := map[string]any{
+		"/tools": map[string]any{
+			"get": map[string]any{
+				"operationId": "listTools",
+				"summary":     "List tools",
+				"description": "Plain-text index with one \"name: description\" line per tool.",
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": "Tool index",
+						"content": map[string]any{
+							"text/plain": map[string]any{"schema": map[string]any{"type": "string"}},
+						},
+					},
+					"default": errorResponseRef(),
+				},
+			},
+		},
+		"/tools/{name}": map[string]any{
+			"get": map[string]any{
+				"operationId": "getTool",
+				"summary":     "Get tool documentation",
+				"parameters": []any{map[string]any{
+					"name":     "name",
+					"in":       "path",
+					"required": true,
+					"schema":   nameSchema,
+				}},
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": "Tool documentation",
+						"content": map[string]any{
+							"application/json": map[string]any{"schema": schemaRef("ToolDoc")},
+						},
+					},
+					"default": errorResponseRef(),
+				},
+			},
+		},
+	}
+
+	for _, t := range tools {
+		paths["/tools/"+t.name+"/run"] = map[string]any{"post": runOperation(t)}
+	}
+
+	return map[string]any{
+		"openapi": openAPIVersion,
+		"info":    info,
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": map[string]any{
+				"Error": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"code": map[string]any{
+							"type": "string",
+							"enum": []any{
+								tap.ErrInvalidRequest, tap.ErrUnauthorized, tapErrForbidden, tap.ErrNotFound,
+								tap.ErrTimeout, tap.ErrRateLimited, tap.ErrExecution, tap.ErrInternalServer,
+							},
+						},
+						"message": map[string]any{"type": "string"},
+					},
+					"required": []any{"code", "message"},
+				},
+				"ToolDoc": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"name":         map[string]any{"type": "string"},
+						"description":  map[string]any{"type": "string"},
+						"parameters":   map[string]any{"type": "object"},
+						"annotations":  map[string]any{"type": "object"},
+						"outputSchema": map[string]any{"type": "object"},
+					},
+					"required": []any{"name", "description", "parameters"},
+				},
+			},
+			"responses": map[string]any{
+				"Error": map[string]any{
+					"description": "TAP error",
+					"content": map[string]any{
+						"application/json": map[string]any{"schema": schemaRef("Error")},
+					},
+				},
+			},
+		},
+	}
+}
+
+// runOperation describes POST /tools/{name}/run for one tool.
+func runOperation(t httpTool) map[string]any {
+	// Results are returned unwrapped over TAP, so lists are plain arrays
+	result := map[string]any{}
+	if t.output != nil {
+		result = t.output
+		if t.outputWrapped {
+			if props, ok := t.output["properties"].(map[string]any); ok {
+				if items, ok := props[outputItemsKey].(map[string]any); ok {
+					result = items
+				}
+			}
+		}
+	}
+
+	op := map[string]any{
+		"operationId": t.name,
+		"summary":     t.description,
+		"requestBody": map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": t.parameters},
+			},
+		},
+		"responses": map[string]any{
+			"200": map[string]any{
+				"description": "Tool result. With Accept: text/event-stream the result is streamed as SSE progress and result events.",
+				"content": map[string]any{
+					"application/json": map[string]any{
+						"schema": map[string]any{
+							"type":       "object",
+							"properties": map[string]any{"result": result},
+							"required":   []any{"result"},
+						},
+					},
+					"text/event-stream": map[string]any{"schema": map[string]any{"type": "string"}},
+				},
+			},
+			"default": errorResponseRef(),
+		},
+	}
+	if t.annotations != nil {
+		op["x-annotations"] = t.annotations
+	}
+	return op
+}
+
+func schemaRef(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+func errorResponseRef() map[string]any {
+	return map[string]any{"$ref": "#/components/responses/Error"}
+}
+
+// serveOpenAPI serves the OpenAPI document for the tools the caller may
+// use, leaving out the same tools as the GET /tools index.
+func (s *Select[T]) serveOpenAPI(tools []httpTool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inv := tapInvocation(r.Context())
+		hidden := s.hiddenTools(r.Context(), *inv)
+		forbidden := s.forbiddenTools(inv.Principal)
+
+		var visible []httpTool
+		for _, t := range tools {
+			if !hidden[t.name] && !forbidden[t.name] {
+				visible = append(visible, t)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.openAPIDoc(visible))
+	})
+}
@@ -0,0 +1,362 @@
+package yeahno
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"charm.land/lipgloss/v2"
+	"charm.land/lipgloss/v2/table"
+	"github.com/google/jsonschema-go/jsonschema"
+	"gopkg.in/yaml.v3"
+)
+
+// outputItemsKey wraps list results, since MCP structured content must be
+// a JSON object.
+const outputItemsKey = "items"
+
+// outputSchemaFor reflects the JSON Schema of an example result. Struct and
+// map results are used as-is; slices are wrapped in {"items": [...]}.
+func outputSchemaFor(example any) (schema *jsonschema.Schema, wrapped bool, err error) {
+	t := reflect.TypeOf(example)
+	if t == nil {
+		return nil, false, fmt.Errorf("output example must not be nil")
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	s, err := jsonschema.ForType(t, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return s, false, nil
+	case reflect.Slice, reflect.Array:
+		return &jsonschema.Schema{
+			Type:          "object",
+			Properties:    map[string]*jsonschema.Schema{outputItemsKey: s},
+			PropertyOrder: []string{outputItemsKey},
+			Required:      []string{outputItemsKey},
+		}, true, nil
+	}
+	return nil, false, fmt.Errorf("output type must be a struct, map or slice, got %s", t)
+}
+
+// outputSchemaMap returns the declared output schema as a generic map.
+func (o Option[T]) outputSchemaMap() (map[string]any, error) {
+	data, err := json.Marshal(o.outputSchema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal output schema: %w", err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal output schema: %w", err)
+	}
+	return m, nil
+}
+
+// structuredResult shapes a handler result to match the output schema and
+// checks that it does, since MCP structured content must be an object of
+// the declared shape.
+func (o Option[T]) structuredResult(result any) (any, error) {
+	if o.outputWrapped {
+		result = map[string]any{outputItemsKey: result}
+	}
+
+	// Validate the JSON form, which is what clients receive
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	var instance any
+	if err := json.Unmarshal(data, &instance); err != nil {
+		return nil, err
+	}
+	if _, ok := instance.(map[string]any); !ok {
+		return nil, fmt.Errorf("result is %T, not an object", result)
+	}
+	if err := o.outputResolved.Validate(instance); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// outputColumns returns the property names of the declared output, or of
+// its list items, in declaration order.
+func (o Option[T]) outputColumns() []string {
+	s := o.outputSchema
+	if s == nil {
+		return nil
+	}
+	if o.outputWrapped {
+		s = s.Properties[outputItemsKey].Items
+		if s == nil {
+			return nil
+		}
+	}
+	return s.PropertyOrder
+}
+
+// tabulate converts a list of records into a header and rows, or a single
+// record into key/value rows with list false. It reports ok false for
+// other results.
+func tabulate(result any, columns []string) (header []string, rows [][]string, list, ok bool) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, nil, false, false
+	}
+
+	var records []map[string]any
+	if err := json.Unmarshal(data, &records); err == nil {
+		if len(columns) == 0 {
+			columns = recordKeys(records...)
+		}
+		header = make([]string, len(columns))
+		for i, c := range columns {
+			header[i] = strings.ToUpper(c)
+		}
+		for _, record := range records {
+			cells := make([]string, len(columns))
+			for i, c := range columns {
+				cells[i] = formatCell(record[c])
+			}
+			rows = append(rows, cells)
+		}
+		return header, rows, true, true
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(data, &record); err == nil {
+		if len(columns) == 0 {
+			columns = recordKeys(record)
+		}
+		for _, c := range columns {
+			rows = append(rows, []string{c, formatCell(record[c])})
+		}
+		return nil, rows, false, true
+	}
+
+	return nil, nil, false, false
+}
+
+// formatTable renders a list of records as an aligned table, or a single
+// record as aligned key/value lines. It reports false for other results.
+func formatTable(result any, columns []string) (string, bool) {
+	header, rows, list, ok := tabulate(result, columns)
+	if !ok {
+		return "", false
+	}
+
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	if list {
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+	} else {
+		for _, row := range rows {
+			fmt.Fprintf(tw, "%s:\t%s\n", row[0], row[1])
+		}
+	}
+	tw.Flush()
+	return strings.TrimRight(b.String(), "\n"), true
+}
+
+// styledTable renders the same layout as formatTable with borders and
+// colors from theme, for terminals.
+func styledTable(result any, columns []string, theme *Theme) (string, bool) {
+	header, rows, list, ok := tabulate(result, columns)
+	if !ok {
+		return "", false
+	}
+
+	headerStyle := lipgloss.NewStyle().Bold(true).Foreground(theme.Primary).Padding(0, 1)
+	cellStyle := lipgloss.NewStyle().Padding(0, 1)
+	t := table.New().
+		Border(lipgloss.RoundedBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(theme.Muted)).
+		Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			// Record keys are styled like headers
+			if row == table.HeaderRow || (!list && col == 0) {
+				return headerStyle
+			}
+			return cellStyle
+		})
+	if list {
+		t = t.Headers(header...)
+	}
+	return t.String(), true
+}
+
+// recordKeys returns the sorted union of keys across records.
+func recordKeys(records ...map[string]any) []string {
+	var keys []string
+	for _, r := range records {
+		for k := range r {
+			if !slices.Contains(keys, k) {
+				keys = append(keys, k)
+			}
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func formatCell(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case map[string]any, []any:
+		data, _ := json.Marshal(x)
+		return string(data)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// Formats accepted by the CLI --output flag. The template format carries
+// its template after an equals sign: template='{{.domain}}'.
+const (
+	outputJSON     = "json"
+	outputYAML     = "yaml"
+	outputTable    = "table"
+	outputText     = "text"
+	outputTemplate = "template"
+)
+
+var outputFormats = []string{outputJSON, outputYAML, outputTable, outputText, outputTemplate}
+
+// outputFormat is a parsed --output value.
+type outputFormat struct {
+	name     string
+	template *template.Template
+}
+
+// parseOutputFormat parses an --output value.
+func parseOutputFormat(value string) (outputFormat, error) {
+	name, text, hasText := strings.Cut(value, "=")
+	if name != "" && !slices.Contains(outputFormats, name) {
+		return outputFormat{}, fmt.Errorf("unknown output format %q (want one of: %s)", name, strings.Join(outputFormats, ", "))
+	}
+	if name != outputTemplate {
+		return outputFormat{name: name}, nil
+	}
+	if !hasText || text == "" {
+		return outputFormat{}, fmt.Errorf("the template format needs a template, e.g. -o template='{{.name}}'")
+	}
+	tmpl, err := template.New("output").Parse(text)
+	if err != nil {
+		return outputFormat{}, fmt.Errorf("invalid output template: %w", err)
+	}
+	return outputFormat{name: name, template: tmpl}, nil
+}
+
+// structured reports whether the format re-encodes the whole result, so
+// rich content is encoded rather than written out.
+func (f outputFormat) structured() bool {
+	return f.name == outputJSON || f.name == outputYAML || f.name == outputTemplate
+}
+
+// render formats a result. columns orders table columns; theme styles
+// tables when styled is true.
+func (f outputFormat) render(result any, columns []string, theme *Theme, styled bool) (string, error) {
+	switch f.name {
+	case outputJSON:
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to encode result: %w", err)
+		}
+		return string(data), nil
+	case outputYAML:
+		return formatYAML(result)
+	case outputTemplate:
+		data, err := jsonValue(result)
+		if err != nil {
+			return "", err
+		}
+		var b strings.Builder
+		if err := f.template.Execute(&b, data); err != nil {
+			return "", fmt.Errorf("failed to render output template: %w", err)
+		}
+		return strings.TrimSuffix(b.String(), "\n"), nil
+	case outputTable:
+		if styled {
+			if out, ok := styledTable(result, columns, theme); ok {
+				return out, nil
+			}
+		}
+		if out, ok := formatTable(result, columns); ok {
+			return out, nil
+		}
+	}
+	// Text, and tables of results that are not records
+	return formatCLIOutput(result), nil
+}
+
+// renderChunk formats a streamed chunk: one compact JSON value per line
+// for json, so output stays valid for jq; as text otherwise.
+func (f outputFormat) renderChunk(chunk any) string {
+	if f.name == outputJSON {
+		if data, err := json.Marshal(chunk); err == nil {
+			return string(data)
+		}
+	}
+	if s, ok := chunk.(string); ok {
+		return strings.TrimSuffix(s, "\n")
+	}
+	return formatCLIOutput(chunk)
+}
+
+// jsonValue converts v to its generic JSON form, so templates and YAML see
+// the same field names as JSON output.
+func jsonValue(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode result: %w", err)
+	}
+	var out any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode result: %w", err)
+	}
+	return out, nil
+}
+
+// formatYAML renders v as block-style YAML, keeping JSON field order.
+func formatYAML(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode result: %w", err)
+	}
+	// JSON is valid YAML; decoding into a node keeps key order
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return "", fmt.Errorf("failed to convert result to YAML: %w", err)
+	}
+	clearStyle(&node)
+	out, err := yaml.Marshal(&node)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode YAML: %w", err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// clearStyle drops the flow and quoting styles carried over from JSON.
+func clearStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		clearStyle(c)
+	}
+}
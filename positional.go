@@ -0,0 +1,116 @@
+package yeahno
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Positional lets the field be given as a command-line argument of its CLI
+// subcommand, at index (starting at 0), in addition to its flag:
+// "complete-task 123" instead of "complete-task --id 123". MCP and TAP
+// schemas are unaffected.
+func (i *Input) Positional(index int) *Input {
+	i.positional = true
+	i.position = index
+	return i
+}
+
+// Variadic makes a positional field take all remaining arguments, joined
+// with spaces, as in "note add buy more milk". It must be the last
+// positional field.
+func (i *Input) Variadic() *Input {
+	i.variadic = true
+	return i
+}
+
+// positionalFields returns the positional fields ordered by index. Indexes
+// must run from 0 without gaps, and only the last field may be variadic.
+func positionalFields(fields []*Input) ([]*Input, error) {
+	var pos []*Input
+	for _, f := range fields {
+		if f.positional {
+			pos = append(pos, f)
+		} else if f.variadic {
+			return nil, fmt.Errorf("field %s: Variadic requires Positional", f.fieldKey())
+		}
+	}
+	slices.SortStableFunc(pos, func(a, b *Input) int { return a.position - b.position })
+
+	for i, f := range pos {
+		if f.position != i {
+			return nil, fmt.Errorf("field %s: positional index %d, want %d", f.fieldKey(), f.position, i)
+		}
+		if f.variadic && i != len(pos)-1 {
+			return nil, fmt.Errorf("field %s: only the last positional field can be variadic", f.fieldKey())
+		}
+		// An optional argument can't be followed by a required one
+		if i > 0 && f.required && !pos[i-1].required {
+			return nil, fmt.Errorf("field %s: required argument after optional %s", f.fieldKey(), pos[i-1].fieldKey())
+		}
+	}
+	return pos, nil
+}
+
+// argUsage returns the field's usage placeholder: "<id>", "[id]",
+// "<words>..." or "[words]...".
+func (i *Input) argUsage() string {
+	name := toKebabCase(i.fieldKey())
+	usage := "[" + name + "]"
+	if i.required {
+		usage = "<" + name + ">"
+	}
+	if i.variadic {
+		usage += "..."
+	}
+	return usage
+}
+
+// positionalArgs rejects more arguments than there are positional fields.
+// Missing arguments are reported after flags and --input are considered,
+// since those can also provide the fields.
+func positionalArgs(pos []*Input) cobra.PositionalArgs {
+	if len(pos) > 0 && pos[len(pos)-1].variadic {
+		return cobra.ArbitraryArgs
+	}
+	return cobra.MaximumNArgs(len(pos))
+}
+
+// positionalValues maps command-line arguments onto positional fields,
+// keyed by field key.
+func positionalValues(pos []*Input, args []string) map[string]string {
+	values := make(map[string]string)
+	for i, f := range pos {
+		if i >= len(args) {
+			break
+		}
+		if f.variadic {
+			values[f.fieldKey()] = strings.Join(args[i:], " ")
+			break
+		}
+		values[f.fieldKey()] = args[i]
+	}
+	return values
+}
+
+// completeArgs completes positional arguments from the fields' suggestions.
+func completeArgs(pos []*Input, fields []*Input) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(pos) == 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		i := min(len(args), len(pos)-1)
+		if i < len(args) && !pos[i].variadic {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		known := positionalValues(pos, args)
+		for _, f := range fields {
+			if flag := cmd.Flags().Lookup(toKebabCase(f.fieldKey())); flag != nil && flag.Changed {
+				known[f.fieldKey()] = flag.Value.String()
+			}
+		}
+		return pos[i].suggestions(cmdContext(cmd), toComplete, known), cobra.ShellCompDirectiveNoFileComp
+	}
+}
@@ -0,0 +1,153 @@
+package yeahno
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+type progressKey struct{}
+
+// ProgressReporter reports how far a long-running handler has got. Get one
+// with Progress; it is safe to use from multiple goroutines.
+type ProgressReporter struct {
+	report func(done, total float64, msg string)
+}
+
+// Progress returns the progress reporter for the current invocation. Each
+// surface delivers reports its own way: MCP progress notifications (when
+// the client sent a progress token), TAP server-sent progress events, a
+// progress bar in the TUI and a progress line on stderr in the CLI.
+//
+// It never returns nil; without a listener reports are dropped.
+func Progress(ctx context.Context) *ProgressReporter {
+	if p, ok := ctx.Value(progressKey{}).(*ProgressReporter); ok {
+		return p
+	}
+	return &ProgressReporter{}
+}
+
+// Report records that done out of total units of work are complete. Use a
+// total of 0 when it is unknown.
+func (p *ProgressReporter) Report(done, total float64, msg string) {
+	if p == nil || p.report == nil {
+		return
+	}
+	p.report(done, total, msg)
+}
+
+func withProgress(ctx context.Context, report func(done, total float64, msg string)) context.Context {
+	return context.WithValue(ctx, progressKey{}, &ProgressReporter{report: report})
+}
+
+// progressFraction converts done/total to the 0-1 range TAP expects.
+func progressFraction(done, total float64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return min(max(done/total, 0), 1)
+}
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// progressPrinter renders progress reports for terminal surfaces. When
+// live, it redraws a single spinner line with a bar in place; otherwise it
+// prints one line per report so logs stay readable.
+type progressPrinter struct {
+	w    io.Writer
+	live bool
+
+	mu    sync.Mutex
+	line  string
+	frame int
+	stop  chan struct{}
+}
+
+func newProgressPrinter(w io.Writer, live bool) *progressPrinter {
+	return &progressPrinter{w: w, live: live}
+}
+
+func (p *progressPrinter) report(done, total float64, msg string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.live {
+		fmt.Fprintln(p.w, progressText(done, total, msg, false))
+		return
+	}
+
+	p.line = progressText(done, total, msg, true)
+	p.draw()
+	if p.stop == nil {
+		p.stop = make(chan struct{})
+		go p.spin(p.stop)
+	}
+}
+
+// spin keeps the spinner moving between reports.
+func (p *progressPrinter) spin(stop chan struct{}) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.mu.Lock()
+			p.frame++
+			p.draw()
+			p.mu.Unlock()
+		}
+	}
+}
+
+func (p *progressPrinter) draw() {
+	fmt.Fprintf(p.w, "\r\033[K%s %s", spinnerFrames[p.frame%len(spinnerFrames)], p.line)
+}
+
+// interrupt clears the live progress line while fn writes other output,
+// then redraws it.
+func (p *progressPrinter) interrupt(fn func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop == nil {
+		fn()
+		return
+	}
+	fmt.Fprint(p.w, "\r\033[K")
+	fn()
+	p.draw()
+}
+
+// finish stops the spinner and clears the progress line.
+func (p *progressPrinter) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop != nil {
+		close(p.stop)
+		p.stop = nil
+		fmt.Fprint(p.w, "\r\033[K")
+	}
+}
+
+// progressText formats a report, with a bar when the total is known.
+func progressText(done, total float64, msg string, bar bool) string {
+	var parts []string
+	if total > 0 {
+		if bar {
+			const width = 20
+			filled := int(progressFraction(done, total) * width)
+			parts = append(parts, "["+strings.Repeat("█", filled)+strings.Repeat("░", width-filled)+"]")
+		}
+		parts = append(parts, fmt.Sprintf("%3.0f%% (%g/%g)", progressFraction(done, total)*100, done, total))
+	} else if done > 0 {
+		parts = append(parts, fmt.Sprintf("(%g)", done))
+	}
+	if msg != "" {
+		parts = append(parts, msg)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,167 @@
+package yeahno
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// PromptDef pairs an MCP prompt with its handler.
+type PromptDef struct {
+	Prompt  *mcp.Prompt
+	Handler mcp.PromptHandler
+}
+
+// PromptData is the data a prompt template is executed with.
+type PromptData struct {
+	// Tool is the flattened tool name of the option.
+	Tool string
+	// Title is the option's display title, or its key.
+	Title string
+	// Description is the option's description.
+	Description string
+	// Args holds the prompt arguments the client supplied, keyed by field key.
+	Args map[string]string
+}
+
+// Prompt sets the text/template used to render the option's MCP prompt,
+// executed with PromptData:
+//
+//	Prompt(`Audit {{.Args.domain}} with the {{.Tool}} tool and summarize any problems.`)
+//
+// Options without a template get a prompt asking the model to use the
+// option's tool with the supplied arguments. Prompt panics if the template
+// does not parse.
+func (o Option[T]) Prompt(text string) Option[T] {
+	o.prompt = template.Must(template.New(o.Key).Option("missingkey=zero").Parse(text))
+	return o
+}
+
+// ToPrompts returns an MCP prompt for every MCP-exposed option, including
+// those of sub-menus. The option's fields become the prompt's arguments.
+func (s *Select[T]) ToPrompts() ([]PromptDef, error) {
+	return s.promptDefs(s.rootScope())
+}
+
+// RegisterPrompts registers the Select's prompts on an MCP server, giving
+// clients slash-command style entry points into the menu.
+func (s *Select[T]) RegisterPrompts(server *mcp.Server) error {
+	defs, err := s.ToPrompts()
+	if err != nil {
+		return err
+	}
+	for _, pd := range defs {
+		server.AddPrompt(pd.Prompt, pd.Handler)
+	}
+	s.installMiddleware(server)
+	return nil
+}
+
+func (s *Select[T]) promptDefs(sc scope) ([]PromptDef, error) {
+	var defs []PromptDef
+	for _, opt := range s.exposedOptions() {
+		if opt.submenu != nil {
+			sub, err := opt.submenu.promptDefs(s.childScope(sc, opt))
+			if err != nil {
+				return nil, err
+			}
+			defs = append(defs, sub...)
+			continue
+		}
+
+		name := joinToolName(sc.prefix, opt.name())
+		var args []*mcp.PromptArgument
+		for _, f := range opt.fields {
+			args = append(args, &mcp.PromptArgument{
+				Name:        f.fieldKey(),
+				Title:       f.title,
+				Description: f.description,
+				Required:    f.required,
+			})
+		}
+
+		defs = append(defs, PromptDef{
+			Prompt: &mcp.Prompt{
+				Name:        name,
+				Title:       opt.displayTitle,
+				Description: opt.desc,
+				Arguments:   args,
+			},
+			Handler: makePromptHandler(name, opt),
+		})
+	}
+	return defs, nil
+}
+
+func makePromptHandler[T comparable](tool string, opt Option[T]) mcp.PromptHandler {
+	return func(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		if err := checkCaller(ctx, *mcpInvocation(req.Session, req.Extra), tool, opt); err != nil {
+			return nil, err
+		}
+
+		input := make(map[string]any, len(req.Params.Arguments))
+		for k, v := range req.Params.Arguments {
+			if v != "" {
+				input[k] = v
+			}
+		}
+		args, err := collectFields(opt.fields, input)
+		if err != nil {
+			return nil, err
+		}
+
+		data := PromptData{
+			Tool:        tool,
+			Title:       opt.displayTitle,
+			Description: opt.desc,
+			Args:        args,
+		}
+		if data.Title == "" {
+			data.Title = opt.Key
+		}
+
+		var text string
+		if opt.prompt != nil {
+			var b strings.Builder
+			if err := opt.prompt.Execute(&b, data); err != nil {
+				return nil, fmt.Errorf("failed to render prompt %s: %w", tool, err)
+			}
+			text = b.String()
+		} else {
+			text = defaultPrompt(data, opt.fields)
+		}
+
+		return &mcp.GetPromptResult{
+			Description: opt.desc,
+			Messages: []*mcp.PromptMessage{
+				{Role: "user", Content: &mcp.TextContent{Text: text}},
+			},
+		}, nil
+	}
+}
+
+// defaultPrompt asks the model to call the option's tool with the given
+// arguments, in field order.
+func defaultPrompt(data PromptData, fields []*Input) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Use the %s tool", data.Tool)
+	if data.Description != "" {
+		fmt.Fprintf(&b, " (%s)", data.Description)
+	}
+	b.WriteString(".")
+
+	var lines []string
+	for _, f := range fields {
+		if v, ok := data.Args[f.fieldKey()]; ok {
+			lines = append(lines, fmt.Sprintf("- %s: %s", f.fieldKey(), v))
+		}
+	}
+	if len(lines) > 0 {
+		b.WriteString(" Arguments:\n")
+		b.WriteString(strings.Join(lines, "\n"))
+	}
+	return b.String()
+}
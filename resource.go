@@ -0,0 +1,207 @@
+package yeahno
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/yosida95/uritemplate/v3"
+)
+
+// ResourceDef pairs an MCP resource or resource template with its handler.
+// Exactly one of Resource and Template is set.
+type ResourceDef struct {
+	Resource *mcp.Resource
+	Template *mcp.ResourceTemplate
+	Handler  mcp.ResourceHandler
+}
+
+// AsResource also exposes the option as an MCP resource, for options that
+// are really data lookups. uri is either a fixed URI ("sites://all") or an
+// RFC 6570 template ("sites://{domain}") whose variables fill the option's
+// fields by key. Reading the resource calls the option's handler.
+func (o Option[T]) AsResource(uri string) Option[T] {
+	o.resourceURI = uri
+	return o
+}
+
+// ToResources returns the resources and resource templates declared with
+// Option.AsResource, including those of sub-menus.
+func (s *Select[T]) ToResources() ([]ResourceDef, error) {
+	return s.resourceDefs(s.rootScope())
+}
+
+// RegisterResources registers the Select's resources and resource
+// templates on an MCP server.
+func (s *Select[T]) RegisterResources(server *mcp.Server) error {
+	defs, err := s.ToResources()
+	if err != nil {
+		return err
+	}
+	for _, rd := range defs {
+		if rd.Template != nil {
+			server.AddResourceTemplate(rd.Template, rd.Handler)
+		} else {
+			server.AddResource(rd.Resource, rd.Handler)
+		}
+	}
+	s.installMiddleware(server)
+	return nil
+}
+
+// RegisterMCP registers everything the Select exposes over MCP: tools and
+// resources.
+func (s *Select[T]) RegisterMCP(server *mcp.Server) error {
+	if err := s.RegisterTools(server); err != nil {
+		return err
+	}
+	return s.RegisterResources(server)
+}
+
+func (s *Select[T]) resourceDefs(sc scope) ([]ResourceDef, error) {
+	var defs []ResourceDef
+	for _, opt := range s.exposedOptions() {
+		if opt.submenu != nil {
+			sub, err := opt.submenu.resourceDefs(s.childScope(sc, opt))
+			if err != nil {
+				return nil, err
+			}
+			defs = append(defs, sub...)
+			continue
+		}
+		if opt.resourceURI == "" {
+			continue
+		}
+
+		name := joinToolName(sc.prefix, opt.name())
+		if opt.needsConfirm() {
+			return nil, fmt.Errorf("resource %s: destructive options cannot be resources", name)
+		}
+		if s.handlerFor(opt) == nil {
+			return nil, fmt.Errorf("resource %s: no handler configured", name)
+		}
+
+		tmpl, err := uritemplate.New(opt.resourceURI)
+		if err != nil {
+			return nil, fmt.Errorf("resource %s: invalid URI template %q: %w", name, opt.resourceURI, err)
+		}
+		if err := checkTemplateFields(tmpl, opt.fields); err != nil {
+			return nil, fmt.Errorf("resource %s: %w", name, err)
+		}
+
+		mimeType := ""
+		if opt.outputSchema != nil {
+			mimeType = "application/json"
+		}
+
+		def := ResourceDef{Handler: s.makeResourceHandler(sc, opt, tmpl)}
+		if len(tmpl.Varnames()) == 0 {
+			def.Resource = &mcp.Resource{
+				URI:         opt.resourceURI,
+				Name:        name,
+				Title:       opt.displayTitle,
+				Description: opt.desc,
+				MIMEType:    mimeType,
+			}
+		} else {
+			def.Template = &mcp.ResourceTemplate{
+				URITemplate: opt.resourceURI,
+				Name:        name,
+				Title:       opt.displayTitle,
+				Description: opt.desc,
+				MIMEType:    mimeType,
+			}
+		}
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
+
+// checkTemplateFields makes sure every template variable is a field and
+// every required field can be filled from the URI.
+func checkTemplateFields(tmpl *uritemplate.Template, fields []*Input) error {
+	vars := make(map[string]bool)
+	for _, v := range tmpl.Varnames() {
+		vars[v] = true
+	}
+	known := make(map[string]bool)
+	for _, f := range fields {
+		key := f.fieldKey()
+		known[key] = true
+		if f.required && !vars[key] {
+			return fmt.Errorf("required field %s is not in the URI template", key)
+		}
+	}
+	for v := range vars {
+		if !known[v] {
+			return fmt.Errorf("URI template variable %s is not a field", v)
+		}
+	}
+	return nil
+}
+
+func (s *Select[T]) makeResourceHandler(sc scope, opt Option[T], tmpl *uritemplate.Template) mcp.ResourceHandler {
+	return func(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+		uri := req.Params.URI
+
+		input := make(map[string]any)
+		values := tmpl.Match(uri)
+		for _, name := range tmpl.Varnames() {
+			if v := values.Get(name); v.Valid() {
+				input[name] = v.String()
+			}
+		}
+		fields, err := collectFields(opt.fields, input)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, stream := withStream(ctx, nil)
+		result, err := s.invoke(ctx, sc, mcpInvocation(req.Session, req.Extra), opt, fields)
+		if err != nil {
+			return nil, err
+		}
+		result = stream.result(result)
+
+		contents, err := resourceContents(uri, result)
+		if err != nil {
+			return nil, err
+		}
+		return &mcp.ReadResourceResult{Contents: contents}, nil
+	}
+}
+
+// resourceContents converts a handler result to resource contents. Text
+// is returned as-is, rich parts by their MIME type and other values as
+// JSON.
+func resourceContents(uri string, result any) ([]*mcp.ResourceContents, error) {
+	parts, ok, err := resultParts(result)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if text, isText := result.(string); isText {
+			return []*mcp.ResourceContents{{URI: uri, MIMEType: "text/plain", Text: text}}, nil
+		}
+		return []*mcp.ResourceContents{{URI: uri, MIMEType: "application/json", Text: resultToString(result)}}, nil
+	}
+
+	contents := make([]*mcp.ResourceContents, 0, len(parts))
+	for _, p := range parts {
+		rc := &mcp.ResourceContents{URI: uri, MIMEType: p.mimeType}
+		switch {
+		case p.kind == partText:
+			rc.MIMEType = "text/plain"
+			rc.Text = p.text
+		case p.kind == partLink:
+			rc.MIMEType = "text/uri-list"
+			rc.Text = p.uri
+		case p.isText():
+			rc.Text = string(p.data)
+		default:
+			rc.Blob = p.data
+		}
+		contents = append(contents, rc)
+	}
+	return contents, nil
+}
@@ -0,0 +1,162 @@
+package yeahno
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+type streamKey struct{}
+
+// StreamWriter delivers incremental output from a handler, such as log
+// lines or report rows, as it is produced. Get one with Stream. It is an
+// io.Writer and safe to use from multiple goroutines.
+type StreamWriter struct {
+	mu     sync.Mutex
+	send   func(chunk any) error
+	sent   int
+	chunks []any // chunks send could not deliver, kept for result
+}
+
+// errUndelivered is returned by a stream's send function for chunks the
+// listener did not receive, so they are kept for the result instead.
+var errUndelivered = errors.New("chunk not delivered")
+
+// Stream returns the stream writer for the current invocation. Chunks are
+// delivered as TAP SSE result events, MCP log notifications, live TUI
+// output and line-by-line CLI output. Surfaces that cannot deliver chunks
+// as they happen return them as the result when the handler returns nil.
+//
+// Handlers can also return an iter.Seq or an iter.Seq2 with an error as the
+// second value; each element is sent as a chunk.
+//
+// It never returns nil; without a listener chunks are dropped.
+func Stream(ctx context.Context) *StreamWriter {
+	if w, ok := ctx.Value(streamKey{}).(*StreamWriter); ok {
+		return w
+	}
+	return &StreamWriter{}
+}
+
+// Send delivers one chunk. Strings are sent as text; other values are
+// encoded as JSON where the surface needs text.
+func (w *StreamWriter) Send(chunk any) error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.sent++
+	// Delivered chunks aren't kept, so long streams to a live listener
+	// don't pile up in memory
+	if w.send != nil {
+		if err := w.send(chunk); !errors.Is(err, errUndelivered) {
+			return err
+		}
+	}
+	w.chunks = append(w.chunks, chunk)
+	return nil
+}
+
+// Write sends p as a text chunk.
+func (w *StreamWriter) Write(p []byte) (int, error) {
+	if err := w.Send(string(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// withStream attaches a stream writer whose chunks are passed to send.
+// Chunks are kept for result when send is nil or returns errUndelivered.
+func withStream(ctx context.Context, send func(chunk any) error) (context.Context, *StreamWriter) {
+	w := &StreamWriter{send: send}
+	return context.WithValue(ctx, streamKey{}, w), w
+}
+
+// streamed reports whether any chunks were sent.
+func (w *StreamWriter) streamed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.sent > 0
+}
+
+// result returns the handler's result, or the undelivered chunks when the
+// handler returned nothing: text chunks joined into lines, other chunks as
+// a list.
+func (w *StreamWriter) result(result any) any {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if result != nil || len(w.chunks) == 0 {
+		return result
+	}
+
+	lines := make([]string, 0, len(w.chunks))
+	for _, c := range w.chunks {
+		s, ok := c.(string)
+		if !ok {
+			return w.chunks
+		}
+		lines = append(lines, strings.TrimSuffix(s, "\n"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// printChunk writes a chunk as one or more lines of terminal output.
+func printChunk(w io.Writer, chunk any) {
+	if s, ok := chunk.(string); ok {
+		fmt.Fprintln(w, strings.TrimSuffix(s, "\n"))
+		return
+	}
+	fmt.Fprintln(w, formatCLIOutput(chunk))
+}
+
+var errorType = reflect.TypeFor[error]()
+
+// drainIterator sends each element of an iter.Seq or iter.Seq2[V, error]
+// result to the stream and returns nil in its place. Other results are
+// returned unchanged.
+func drainIterator(ctx context.Context, result any) (any, error) {
+	v := reflect.ValueOf(result)
+	if !v.IsValid() || !isIterator(v.Type()) || v.IsNil() {
+		return result, nil
+	}
+
+	stream := Stream(ctx)
+	var err error
+	yieldType := v.Type().In(0)
+	yield := reflect.MakeFunc(yieldType, func(args []reflect.Value) []reflect.Value {
+		if len(args) == 2 && !args[1].IsNil() {
+			err = args[1].Interface().(error)
+		} else if sendErr := stream.Send(args[0].Interface()); sendErr != nil {
+			err = sendErr
+		} else if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		return []reflect.Value{reflect.ValueOf(err == nil)}
+	})
+	v.Call([]reflect.Value{yield})
+	return nil, err
+}
+
+// isIterator reports whether t is func(yield func(V) bool) or
+// func(yield func(V, error) bool).
+func isIterator(t reflect.Type) bool {
+	if t == nil || t.Kind() != reflect.Func || t.NumIn() != 1 || t.NumOut() != 0 {
+		return false
+	}
+	y := t.In(0)
+	if y.Kind() != reflect.Func || y.NumOut() != 1 || y.Out(0).Kind() != reflect.Bool {
+		return false
+	}
+	switch y.NumIn() {
+	case 1:
+		return true
+	case 2:
+		return y.In(1) == errorType
+	}
+	return false
+}
@@ -1,8 +1,10 @@
 package yeahno
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
 	"regexp"
@@ -94,6 +96,95 @@ func toSnakeCase(s string) string {
 
 func intPtr(i int) *int { return &i }
 
+// jsonSchema builds the JSON Schema property for a field.
+func (i *Input) jsonSchema() *jsonschema.Schema {
+	schema := &jsonschema.Schema{Type: i.kind.schemaType()}
+	if i.title != "" {
+		schema.Description = i.title
+	}
+	if i.kind == KindString && i.charLimit > 0 {
+		schema.MaxLength = intPtr(i.charLimit)
+	}
+	if i.format != "" {
+		if fv, ok := formatValidators[i.format]; ok {
+			schema.Format = fv.schemaFormat
+		}
+	}
+	for _, v := range i.enum {
+		schema.Enum = append(schema.Enum, v)
+	}
+	return schema
+}
+
+// schema returns the option's JSON Schema, either reflected from its
+// argument struct or built from its fields.
+func (o Option[T]) schema() *jsonschema.Schema {
+	if o.inputSchema != nil {
+		return o.inputSchema
+	}
+
+	properties := make(map[string]*jsonschema.Schema)
+	var propertyOrder []string
+	var required []string
+
+	for _, f := range o.fields {
+		fKey := f.fieldKey()
+		properties[fKey] = f.jsonSchema()
+		propertyOrder = append(propertyOrder, fKey)
+
+		if f.required {
+			required = append(required, fKey)
+		}
+	}
+
+	jschema := &jsonschema.Schema{
+		Type:          "object",
+		Properties:    properties,
+		PropertyOrder: propertyOrder,
+	}
+	if len(required) > 0 {
+		jschema.Required = required
+	}
+	return jschema
+}
+
+// schemaMap returns the option's JSON Schema as a generic map, the form
+// both MCP and TAP clients receive.
+func (o Option[T]) schemaMap() (map[string]any, error) {
+	if err := checkEnums(o.fields); err != nil {
+		return nil, err
+	}
+	jschema := o.schema()
+	schemaBytes, err := json.Marshal(jschema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal schema: %w", err)
+	}
+	var schemaMap map[string]any
+	if err := json.Unmarshal(schemaBytes, &schemaMap); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal schema: %w", err)
+	}
+
+	if len(jschema.Required) > 0 {
+		schemaMap["required"] = jschema.Required
+	}
+	return schemaMap, nil
+}
+
+// decodeArguments decodes tool call arguments, keeping numbers as
+// json.Number so integers survive without float rounding.
+func decodeArguments(args json.RawMessage) (map[string]any, error) {
+	var input map[string]any
+	dec := json.NewDecoder(bytes.NewReader(args))
+	dec.UseNumber()
+	if err := dec.Decode(&input); err != nil {
+		return nil, err
+	}
+	if input == nil {
+		input = make(map[string]any)
+	}
+	return input, nil
+}
+
 func resultToString(result any) string {
 	switch v := result.(type) {
 	case string:
@@ -106,171 +197,283 @@ func resultToString(result any) string {
 	}
 }
 
-func (s *Select[T]) ToTools() ([]ToolDef, error) {
-	if s.handler == nil {
-		return nil, fmt.Errorf("no handler configured")
+// toolAnnotations returns the option's MCP behavior hints, or nil if none
+// were set.
+func (o Option[T]) toolAnnotations() *mcp.ToolAnnotations {
+	if !o.readOnly && o.destructive == nil && !o.idempotent && o.openWorld == nil && o.displayTitle == "" {
+		return nil
+	}
+	// DestructiveHint is only sent when set, since clients read a missing
+	// hint as destructive
+	return &mcp.ToolAnnotations{
+		ReadOnlyHint:    o.readOnly,
+		DestructiveHint: o.destructive,
+		IdempotentHint:  o.idempotent,
+		OpenWorldHint:   o.openWorld,
+		Title:           o.displayTitle,
 	}
+}
+
+// hints describes the option's behavior hints in words, for CLI help.
+func (o Option[T]) hints() []string {
+	var hints []string
+	if o.readOnly {
+		hints = append(hints, "read-only")
+	}
+	if o.isDestructive() {
+		hints = append(hints, "destructive")
+	}
+	if o.idempotent {
+		hints = append(hints, "idempotent")
+	}
+	if o.openWorld != nil {
+		if *o.openWorld {
+			hints = append(hints, "open-world")
+		} else {
+			hints = append(hints, "closed-world")
+		}
+	}
+	return hints
+}
 
-	var mcpOptions []Option[T]
-	for _, o := range s.options {
-		if o.mcp {
-			mcpOptions = append(mcpOptions, o)
+// joinToolName prefixes a snake_cased tool name.
+func joinToolName(prefix, name string) string {
+	name = toSnakeCase(name)
+	if prefix != "" {
+		return prefix + "_" + name
+	}
+	return name
+}
+
+// submenuPrefix composes the tool prefix for an option's submenu: the
+// parent prefix followed by the child's ToolPrefix, or the option name if
+// the child has none.
+func submenuPrefix[T comparable](prefix string, opt Option[T]) string {
+	if p := opt.submenu.prefix(); p != "" {
+		if prefix != "" {
+			return prefix + "_" + p
 		}
+		return p
 	}
+	return joinToolName(prefix, opt.name())
+}
 
-	if len(mcpOptions) == 0 {
-		mcpOptions = s.options
+func (s *Select[T]) ToTools() ([]ToolDef, error) {
+	return s.toolDefs(s.rootScope())
+}
+
+func (s *Select[T]) toolDefs(sc scope) ([]ToolDef, error) {
+	mcpOptions := s.exposedOptions()
+	if err := s.checkHandlers(mcpOptions); err != nil {
+		return nil, err
 	}
 
 	var tools []ToolDef
 	for _, opt := range mcpOptions {
-		toolName := opt.toolName
-		if toolName == "" {
-			toolName = opt.Key
+		if opt.submenu != nil {
+			defs, err := opt.submenu.toolDefs(s.childScope(sc, opt))
+			if err != nil {
+				return nil, err
+			}
+			tools = append(tools, defs...)
+			continue
 		}
-		toolName = toSnakeCase(toolName)
 
-		if s.toolPrefix != "" {
-			toolName = s.toolPrefix + "_" + toolName
-		}
+		toolName := joinToolName(sc.prefix, opt.name())
 
 		desc := opt.desc
 		if desc == "" {
 			desc = opt.Key
 		}
 
-		properties := make(map[string]*jsonschema.Schema)
-		var propertyOrder []string
-		var required []string
-
-		for _, f := range opt.fields {
-			fKey := f.key
-			if fKey == "" {
-				fKey = toSnakeCase(f.title)
-			}
-
-			schema := &jsonschema.Schema{Type: "string"}
-			if f.title != "" {
-				schema.Description = f.title
-			}
-			if f.charLimit > 0 {
-				schema.MaxLength = intPtr(f.charLimit)
-			}
-			if f.format != "" {
-				if fv, ok := formatValidators[f.format]; ok {
-					schema.Format = fv.schemaFormat
-				}
-			}
-			properties[fKey] = schema
-			propertyOrder = append(propertyOrder, fKey)
-
-			if f.required {
-				required = append(required, fKey)
-			}
-		}
-
-		jschema := &jsonschema.Schema{
-			Type:          "object",
-			Properties:    properties,
-			PropertyOrder: propertyOrder,
-		}
-		if len(required) > 0 {
-			jschema.Required = required
-		}
-
-		schemaBytes, err := json.Marshal(jschema)
+		schemaMap, err := opt.schemaMap()
 		if err != nil {
-			return nil, fmt.Errorf("failed to marshal schema for tool %s: %w", toolName, err)
+			return nil, fmt.Errorf("failed to build schema for tool %s: %w", toolName, err)
 		}
-		var schemaMap map[string]any
-		if err := json.Unmarshal(schemaBytes, &schemaMap); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal schema for tool %s: %w", toolName, err)
-		}
-		
-		if len(required) > 0 {
-			schemaMap["required"] = required
+
+		if opt.needsConfirm() {
+			addSchemaProperty(schemaMap, confirmArg, map[string]any{
+				"type":        "boolean",
+				"description": "Set to true only after the user has confirmed this destructive action",
+			})
 		}
 
 		tool := &mcp.Tool{
 			Name:        toolName,
+			Title:       opt.displayTitle,
 			Description: desc,
 			InputSchema: schemaMap,
+			Annotations: opt.toolAnnotations(),
+		}
+		if opt.outputSchema != nil {
+			outputSchema, err := opt.outputSchemaMap()
+			if err != nil {
+				return nil, fmt.Errorf("failed to build output schema for tool %s: %w", toolName, err)
+			}
+			tool.OutputSchema = outputSchema
 		}
 
-		handler := s.makeToolHandler(opt)
+		handler := s.makeToolHandler(sc, opt)
 		tools = append(tools, ToolDef{Tool: tool, Handler: handler})
 	}
 
 	return tools, nil
 }
 
-func (s *Select[T]) makeToolHandler(opt Option[T]) mcp.ToolHandler {
+func (s *Select[T]) makeToolHandler(sc scope, opt Option[T]) mcp.ToolHandler {
 	return func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		var input map[string]any
-		if err := json.Unmarshal(req.Params.Arguments, &input); err != nil {
+		tool := joinToolName(sc.prefix, opt.name())
+		inv := mcpInvocation(req.Session, req.Extra)
+		if err := checkCaller(ctx, *inv, tool, opt); err != nil {
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{&mcp.TextContent{Text: err.Error()}},
+				IsError: true,
+			}, nil
+		}
+
+		input, err := decodeArguments(req.Params.Arguments)
+		if err != nil {
 			return &mcp.CallToolResult{
 				Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("failed to parse arguments: %v", err)}},
 				IsError: true,
 			}, nil
 		}
 
-		fields := make(map[string]string)
-		for _, f := range opt.fields {
-			fKey := f.key
-			if fKey == "" {
-				fKey = toSnakeCase(f.title)
+		// Ask the user for missing or invalid fields rather than letting
+		// the model guess
+		if len(fieldProblems(opt.fields, input)) > 0 && supportsElicitation(req.Session) {
+			if err := elicitFields(ctx, req.Session, tool, opt.fields, input); err != nil {
+				return &mcp.CallToolResult{
+					Content: []mcp.Content{&mcp.TextContent{Text: err.Error()}},
+					IsError: true,
+				}, nil
 			}
+		}
 
-			if val, ok := input[fKey].(string); ok {
-				limit := maxFieldLength
-				if f.charLimit > 0 {
-					limit = f.charLimit
-				}
-				if len(val) > limit {
-					return &mcp.CallToolResult{
-						Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("%s exceeds maximum length of %d", fKey, limit)}},
-						IsError: true,
-					}, nil
-				}
-				if f.format != "" {
-					if fv, ok := formatValidators[f.format]; ok {
-						if err := fv.validate(val); err != nil {
-							return &mcp.CallToolResult{
-								Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("invalid %s: %v", fKey, err)}},
-								IsError: true,
-							}, nil
-						}
-					}
-				}
-				if f.validate != nil {
-					if err := f.validate(val); err != nil {
-						return &mcp.CallToolResult{
-							Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("invalid %s: %v", fKey, err)}},
-							IsError: true,
-						}, nil
-					}
-				}
-				fields[fKey] = val
-			} else if f.required {
+		fields, err := collectFields(opt.fields, input)
+		if err != nil {
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{&mcp.TextContent{Text: err.Error()}},
+				IsError: true,
+			}, nil
+		}
+
+		if opt.needsConfirm() {
+			if err := confirmMCP(ctx, req, input, opt.confirmMessage(fields)); err != nil {
 				return &mcp.CallToolResult{
-					Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("missing required field: %s", fKey)}},
+					Content: []mcp.Content{&mcp.TextContent{Text: err.Error()}},
 					IsError: true,
 				}, nil
 			}
 		}
 
-		result, err := s.handler(ctx, opt.Value, fields)
+		// Handlers called directly, as with ToTools, may have no session
+		if token := req.Params.GetProgressToken(); token != nil && req.Session != nil {
+			ctx = withProgress(ctx, func(done, total float64, msg string) {
+				req.Session.NotifyProgress(ctx, &mcp.ProgressNotificationParams{
+					ProgressToken: token,
+					Progress:      done,
+					Total:         total,
+					Message:       msg,
+				})
+			})
+		}
+
+		// Streamed chunks go out as log notifications. They stay off the
+		// progress token, whose values must only increase. Chunks the
+		// client doesn't receive, because it set no log level, are added
+		// to the result
+		var send func(chunk any) error
+		if req.Session != nil {
+			send = func(chunk any) error {
+				delivered := false
+				req.Session.Log(context.WithValue(ctx, logDeliveredKey{}, &delivered),
+					&mcp.LoggingMessageParams{Level: "info", Logger: tool, Data: chunk})
+				if !delivered {
+					return errUndelivered
+				}
+				return nil
+			}
+		}
+		ctx, stream := withStream(ctx, send)
+
+		result, err := s.invoke(ctx, sc, inv, opt, fields)
+		if errors.Is(err, ErrUnavailable) {
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{&mcp.TextContent{Text: err.Error()}},
+				IsError: true,
+			}, nil
+		}
 		if err != nil {
 			return &mcp.CallToolResult{
 				Content: []mcp.Content{&mcp.TextContent{Text: "tool execution failed"}},
 				IsError: true,
 			}, nil
 		}
+		undelivered := stream.result(nil)
+		if result == nil {
+			result, undelivered = undelivered, nil
+		}
+		if result == nil && stream.streamed() {
+			result = "Output was streamed as log messages."
+		}
+
+		res := opt.callToolResult(result)
+		if undelivered != nil && !res.IsError {
+			res.Content = append([]mcp.Content{&mcp.TextContent{Text: resultToString(undelivered)}}, res.Content...)
+		}
+		return res, nil
+	}
+}
 
-		text := resultToString(result)
+// callToolResult converts a handler result into MCP content.
+func (o Option[T]) callToolResult(result any) *mcp.CallToolResult {
+	if parts, ok, err := resultParts(result); ok {
+		if err != nil {
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{&mcp.TextContent{Text: "tool execution failed"}},
+				IsError: true,
+			}
+		}
+		content := make([]mcp.Content, len(parts))
+		for i, p := range parts {
+			content[i] = p.mcpContent()
+		}
+		return &mcp.CallToolResult{Content: content}
+	}
+
+	if o.outputSchema != nil {
+		structured, err := o.structuredResult(result)
+		if err != nil {
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("result does not match the declared output: %v", err)}},
+				IsError: true,
+			}
+		}
 		return &mcp.CallToolResult{
-			Content: []mcp.Content{&mcp.TextContent{Text: text}},
-		}, nil
+			Content:           []mcp.Content{&mcp.TextContent{Text: resultToString(structured)}},
+			StructuredContent: structured,
+		}
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: resultToString(result)}},
+	}
+}
+
+// logDeliveredKey carries a flag that markLogDelivered sets when a log
+// notification is actually sent, which Session.Log doesn't report.
+type logDeliveredKey struct{}
+
+// markLogDelivered is sending middleware that flags delivered log
+// notifications for streamed chunks.
+func markLogDelivered(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		res, err := next(ctx, method, req)
+		if delivered, ok := ctx.Value(logDeliveredKey{}).(*bool); ok && err == nil && method == "notifications/message" {
+			*delivered = true
+		}
+		return res, err
 	}
 }
 
@@ -282,5 +485,6 @@ func (s *Select[T]) RegisterTools(server *mcp.Server) error {
 	for _, td := range tools {
 		server.AddTool(td.Tool, td.Handler)
 	}
+	s.installMiddleware(server)
 	return nil
 }
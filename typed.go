@@ -0,0 +1,224 @@
+package yeahno
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// TypedOption is an Option whose fields are derived from the struct A.
+// Call Handle to receive decoded arguments, or use the embedded Option's
+// builder methods to fall back to the Select handler.
+type TypedOption[T comparable, A any] struct {
+	Option[T]
+}
+
+// NewTypedOption creates an option whose input fields, JSON Schema, CLI
+// flags and TUI prompts are all derived from the struct type A.
+//
+// Field names come from `json` tags, descriptions from `jsonschema` tags and
+// fields without omitempty are required. The `yeahno` tag adds presentation
+// and validation hints as comma-separated key=value pairs:
+//
+//	type AddSiteArgs struct {
+//		Domain string `json:"domain" yeahno:"title=Domain,format=domain"`
+//		Depth  int    `json:"depth,omitempty" jsonschema:"crawl depth"`
+//		Mode   string `json:"mode,omitempty" yeahno:"enum=fast|full"`
+//	}
+//
+// NewTypedOption panics if A is not a struct of string, integer, number or
+// boolean fields.
+func NewTypedOption[T comparable, A any](key string, value T) TypedOption[T, A] {
+	schema, fields, err := typedFields(reflect.TypeFor[A]())
+	if err != nil {
+		panic(fmt.Sprintf("yeahno: NewTypedOption[%s]: %v", reflect.TypeFor[A](), err))
+	}
+	return TypedOption[T, A]{Option[T]{
+		Key:         key,
+		Value:       value,
+		fields:      fields,
+		inputSchema: schema,
+	}}
+}
+
+// Handle sets a handler for this option that receives the decoded struct
+// instead of the raw field map. It takes precedence over the Select handler.
+func (o TypedOption[T, A]) Handle(h func(ctx context.Context, value T, args A) (any, error)) Option[T] {
+	opt := o.Option
+	fields := opt.fields
+	opt.handler = func(ctx context.Context, value T, values map[string]string) (any, error) {
+		args, err := decodeFields[A](fields, values)
+		if err != nil {
+			return nil, err
+		}
+		return h(ctx, value, args)
+	}
+	return opt
+}
+
+// decodeFields converts canonical field values into the struct A.
+func decodeFields[A any](fields []*Input, values map[string]string) (A, error) {
+	var args A
+	raw := make(map[string]any, len(values))
+	for _, f := range fields {
+		key := f.fieldKey()
+		val, ok := values[key]
+		if !ok {
+			continue
+		}
+		switch f.kind {
+		case KindInteger, KindNumber:
+			raw[key] = json.Number(val)
+		case KindBoolean:
+			raw[key] = BoolField(values, key)
+		default:
+			raw[key] = val
+		}
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return args, fmt.Errorf("failed to encode arguments: %w", err)
+	}
+	if err := json.Unmarshal(data, &args); err != nil {
+		return args, fmt.Errorf("failed to decode arguments: %w", err)
+	}
+	return args, nil
+}
+
+// typedFields reflects t into a JSON Schema and the matching Input fields.
+func typedFields(t reflect.Type) (*jsonschema.Schema, []*Input, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("argument type must be a struct")
+	}
+	schema, err := jsonschema.ForType(t, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tags := make(map[string]string)
+	for _, sf := range reflect.VisibleFields(t) {
+		if name := jsonFieldName(sf); name != "" {
+			tags[name] = sf.Tag.Get("yeahno")
+		}
+	}
+
+	required := make(map[string]bool)
+	for _, name := range schema.Required {
+		required[name] = true
+	}
+
+	var fields []*Input
+	for _, name := range schema.PropertyOrder {
+		prop := schema.Properties[name]
+		f := NewInput().Key(name).Required(required[name])
+
+		switch propertyType(prop) {
+		case "string":
+		case "integer":
+			f.Integer()
+		case "number":
+			f.Number()
+		case "boolean":
+			f.Boolean()
+		default:
+			return nil, nil, fmt.Errorf("field %s: only string, integer, number and boolean fields are supported", name)
+		}
+
+		if err := applyFieldTag(f, tags[name]); err != nil {
+			return nil, nil, fmt.Errorf("field %s: %w", name, err)
+		}
+		if f.title == "" {
+			f.title = prop.Description
+		}
+		if f.title == "" {
+			f.title = name
+		}
+
+		// Carry the tag hints into the reflected schema
+		if prop.Description == "" {
+			prop.Description = f.title
+		}
+		if f.kind == KindString && f.charLimit > 0 {
+			prop.MaxLength = intPtr(f.charLimit)
+		}
+		if fv, ok := formatValidators[f.format]; ok {
+			prop.Format = fv.schemaFormat
+		}
+		for _, v := range f.enum {
+			prop.Enum = append(prop.Enum, v)
+		}
+
+		fields = append(fields, f)
+	}
+	return schema, fields, nil
+}
+
+// applyFieldTag applies a `yeahno:"title=...,format=..."` struct tag.
+func applyFieldTag(f *Input, tag string) error {
+	if tag == "" {
+		return nil
+	}
+	for _, part := range strings.Split(tag, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch strings.TrimSpace(k) {
+		case "title":
+			f.Title(v)
+		case "description":
+			f.Description(v)
+		case "placeholder":
+			f.Placeholder(v)
+		case "format":
+			f.Format(v)
+		case "enum":
+			if f.kind != KindString {
+				return fmt.Errorf("enum is only supported on string fields")
+			}
+			f.Enum(strings.Split(v, "|")...)
+		case "charlimit":
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid charlimit %q", v)
+			}
+			f.CharLimit(n)
+		default:
+			return fmt.Errorf("unknown yeahno tag key %q", k)
+		}
+	}
+	return nil
+}
+
+// propertyType returns the non-null JSON Schema type of a property.
+func propertyType(s *jsonschema.Schema) string {
+	if s.Type != "" {
+		return s.Type
+	}
+	for _, t := range s.Types {
+		if t != "null" {
+			return t
+		}
+	}
+	return ""
+}
+
+// jsonFieldName returns the JSON name of a struct field, or "" if omitted.
+func jsonFieldName(sf reflect.StructField) string {
+	if !sf.IsExported() || sf.Anonymous {
+		return ""
+	}
+	name := sf.Name
+	if tag, ok := sf.Tag.Lookup("json"); ok {
+		n, _, _ := strings.Cut(tag, ",")
+		if n == "-" && tag == "-" {
+			return ""
+		}
+		if n != "" {
+			name = n
+		}
+	}
+	return name
+}
@@ -0,0 +1,252 @@
+package yeahno
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/mhpenta/tap-go"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ErrUnavailable is returned when a caller invokes an option that is
+// hidden from it by VisibleWhen or disabled for it by EnabledWhen.
+var ErrUnavailable = errors.New("not available")
+
+// unavailableError reports which option was unavailable and whether it was
+// hidden (as if it did not exist) or only disabled.
+type unavailableError struct {
+	tool   string
+	hidden bool
+}
+
+func (e *unavailableError) Error() string {
+	if e.hidden {
+		return fmt.Sprintf("unknown tool %q", e.tool)
+	}
+	return fmt.Sprintf("%s is not available", e.tool)
+}
+
+func (e *unavailableError) Is(target error) bool { return target == ErrUnavailable }
+
+// VisibleWhen shows the option only to callers for which fn returns true.
+// fn is evaluated for every caller with the invocation available through
+// InvocationFrom, so options can depend on the MCP session, the TAP
+// request or the CLI user. Hidden options are left out of MCP tool lists,
+// TAP indexes, CLI help and TUI menus, and calling one fails with
+// ErrUnavailable.
+func (o Option[T]) VisibleWhen(fn func(ctx context.Context) bool) Option[T] {
+	o.visibleWhen = fn
+	return o
+}
+
+// EnabledWhen allows the option only for callers for which fn returns
+// true. Unlike VisibleWhen the option is still listed, and greyed out in
+// the TUI; calling it fails with ErrUnavailable.
+func (o Option[T]) EnabledWhen(fn func(ctx context.Context) bool) Option[T] {
+	o.enabledWhen = fn
+	return o
+}
+
+// optionInfo describes a flattened, exposed option independently of the
+// Select's value type.
+type optionInfo struct {
+	name        string
+	resourceURI string
+	fields      []*Input
+	value       any
+	visible     func(ctx context.Context) bool
+	enabled     func(ctx context.Context) bool
+	roles       []string
+}
+
+func (s *Select[T]) optionInfos(sc scope) []optionInfo {
+	var infos []optionInfo
+	for _, opt := range s.exposedOptions() {
+		if opt.submenu != nil {
+			// Roles required by a submenu apply to everything in it
+			for _, info := range opt.submenu.optionInfos(s.childScope(sc, opt)) {
+				info.roles = append(slices.Clone(opt.roles), info.roles...)
+				infos = append(infos, info)
+			}
+			continue
+		}
+		infos = append(infos, optionInfo{
+			name:        joinToolName(sc.prefix, opt.name()),
+			resourceURI: opt.resourceURI,
+			fields:      opt.fields,
+			value:       opt.Value,
+			visible:     opt.visibleWhen,
+			enabled:     opt.enabledWhen,
+			roles:       opt.roles,
+		})
+	}
+	return infos
+}
+
+// checkAvailable evaluates the option's predicates for the caller in ctx.
+func (o Option[T]) checkAvailable(ctx context.Context, tool string) error {
+	if o.visibleWhen != nil && !o.visibleWhen(ctx) {
+		return &unavailableError{tool: tool, hidden: true}
+	}
+	if o.enabledWhen != nil && !o.enabledWhen(ctx) {
+		return &unavailableError{tool: tool}
+	}
+	return nil
+}
+
+// callerContext returns ctx with the invocation a predicate sees for opt.
+func callerContext[T comparable](ctx context.Context, inv Invocation, tool string, opt Option[T]) context.Context {
+	inv.Tool = tool
+	inv.Value = opt.Value
+	return withInvocation(ctx, &inv)
+}
+
+// checkCaller checks the option's availability for the caller described by
+// inv, so unavailable calls fail before any prompt or confirmation.
+func checkCaller[T comparable](ctx context.Context, inv Invocation, tool string, opt Option[T]) error {
+	return opt.checkAvailable(callerContext(ctx, inv, tool, opt), tool)
+}
+
+// tapUnavailable converts ErrUnavailable into the matching TAP error.
+func tapUnavailable(err error) error {
+	var ue *unavailableError
+	if !errors.As(err, &ue) {
+		return err
+	}
+	if ue.hidden {
+		return tap.Errorf(tap.ErrNotFound, "tool %q not found", ue.tool)
+	}
+	return tap.NewError(tap.ErrInvalidRequest, ue.Error())
+}
+
+// enabledFor reports whether the option may be called in ctx.
+func (o Option[T]) enabledFor(ctx context.Context) bool {
+	return o.enabledWhen == nil || o.enabledWhen(ctx)
+}
+
+// visibleTo reports whether the option is shown in ctx.
+func (o Option[T]) visibleTo(ctx context.Context) bool {
+	return o.visibleWhen == nil || o.visibleWhen(ctx)
+}
+
+// hiddenTools returns the names of tools hidden from the caller in ctx.
+// inv describes the caller; Tool and Value are filled in per option.
+func (s *Select[T]) hiddenTools(ctx context.Context, inv Invocation) map[string]bool {
+	hidden := make(map[string]bool)
+	for _, info := range s.optionInfos(s.rootScope()) {
+		if info.visible == nil {
+			continue
+		}
+		caller := inv
+		caller.Tool = info.name
+		caller.Value = info.value
+		if !info.visible(withInvocation(ctx, &caller)) {
+			hidden[info.name] = true
+		}
+	}
+	return hidden
+}
+
+// availableTo reports whether the option may be used by the caller
+// described by inv.
+func (info optionInfo) availableTo(ctx context.Context, inv Invocation) bool {
+	inv.Tool = info.name
+	inv.Value = info.value
+	ctx = withInvocation(ctx, &inv)
+	return (info.visible == nil || info.visible(ctx)) && (info.enabled == nil || info.enabled(ctx))
+}
+
+// filterMCPLists removes tools, prompts and resources hidden from the
+// requesting session from tools/list, prompts/list, resources/list and
+// resources/templates/list responses.
+func (s *Select[T]) filterMCPLists(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		res, err := next(ctx, method, req)
+		if err != nil {
+			return res, err
+		}
+		switch res.(type) {
+		case *mcp.ListToolsResult, *mcp.ListPromptsResult, *mcp.ListResourcesResult, *mcp.ListResourceTemplatesResult:
+		default:
+			return res, nil
+		}
+
+		ss, _ := req.GetSession().(*mcp.ServerSession)
+		hidden := s.hiddenTools(ctx, *mcpInvocation(ss, req.GetExtra()))
+		if len(hidden) == 0 {
+			return res, nil
+		}
+		switch list := res.(type) {
+		case *mcp.ListToolsResult:
+			filtered := *list
+			filtered.Tools = slices.DeleteFunc(slices.Clone(list.Tools), func(t *mcp.Tool) bool { return hidden[t.Name] })
+			return &filtered, nil
+		case *mcp.ListPromptsResult:
+			filtered := *list
+			filtered.Prompts = slices.DeleteFunc(slices.Clone(list.Prompts), func(p *mcp.Prompt) bool { return hidden[p.Name] })
+			return &filtered, nil
+		case *mcp.ListResourcesResult:
+			filtered := *list
+			filtered.Resources = slices.DeleteFunc(slices.Clone(list.Resources), func(r *mcp.Resource) bool { return hidden[r.Name] })
+			return &filtered, nil
+		case *mcp.ListResourceTemplatesResult:
+			filtered := *list
+			filtered.ResourceTemplates = slices.DeleteFunc(slices.Clone(list.ResourceTemplates), func(r *mcp.ResourceTemplate) bool { return hidden[r.Name] })
+			return &filtered, nil
+		}
+		return res, nil
+	}
+}
+
+// filterTAPTools limits TAP callers to the tools they may use. Hidden
+// tools and tools the caller lacks a role for are dropped from the
+// GET /tools index; their docs and run endpoints answer not_found for
+// hidden tools and unauthorized or forbidden for missing roles.
+func (s *Select[T]) filterTAPTools(tools []httpTool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inv := tapInvocation(r.Context())
+		hidden := s.hiddenTools(r.Context(), *inv)
+		forbidden := s.forbiddenTools(inv.Principal)
+		if len(hidden) == 0 && len(forbidden) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if name := r.PathValue("name"); name != "" {
+			switch {
+			case hidden[name]:
+				writeTAPError(w, http.StatusNotFound, tap.ErrNotFound, fmt.Sprintf("tool %q not found", name))
+			case forbidden[name] && inv.Principal == nil:
+				writeTAPError(w, http.StatusUnauthorized, tap.ErrUnauthorized, "authentication required")
+			case forbidden[name]:
+				writeTAPError(w, http.StatusForbidden, tapErrForbidden, fmt.Sprintf("%s may not use %s", inv.Principal.Name, name))
+			default:
+				next.ServeHTTP(w, r)
+			}
+			return
+		}
+
+		// The index is rebuilt in tap-go's format from the allowed tools,
+		// as descriptions may span several lines
+		w.Header().Set("Content-Type", "text/plain")
+		if desc := s.tapDescription(); desc != "" {
+			fmt.Fprintf(w, "%s\n\n", desc)
+		}
+		for _, t := range tools {
+			if !hidden[t.name] && !forbidden[t.name] {
+				fmt.Fprintf(w, "%s: %s\n", t.name, t.description)
+			}
+		}
+	})
+}
+
+// writeTAPError writes an error in tap-go's JSON error format.
+func writeTAPError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(tap.NewError(code, msg))
+}
@@ -3,8 +3,16 @@ package yeahno
 import (
 	"context"
 	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"text/template"
 
 	"github.com/charmbracelet/huh"
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
 )
 
 type Option[T comparable] struct {
@@ -12,10 +20,32 @@ type Option[T comparable] struct {
 	Value    T
 	selected bool
 
-	mcp      bool
-	fields   []*Input
-	desc     string
-	toolName string
+	mcp         bool
+	fields      []*Input
+	desc        string
+	toolName    string
+	handler     func(ctx context.Context, value T, fields map[string]string) (any, error)
+	inputSchema *jsonschema.Schema // set for options derived from a struct
+	submenu     Menu
+
+	destructive   *bool
+	confirmPrompt string
+
+	readOnly     bool
+	idempotent   bool
+	openWorld    *bool
+	displayTitle string
+
+	resourceURI string
+	disabled    bool
+	visibleWhen func(ctx context.Context) bool
+	enabledWhen func(ctx context.Context) bool
+	roles       []string
+	prompt      *template.Template
+
+	outputSchema   *jsonschema.Schema
+	outputResolved *jsonschema.Resolved // outputSchema, ready to validate results
+	outputWrapped  bool                 // slice results are returned as {"items": [...]}
 }
 
 func NewOption[T comparable](key string, value T) Option[T] {
@@ -62,6 +92,113 @@ func (o Option[T]) ToolName(name string) Option[T] {
 	return o
 }
 
+// Handler sets a handler for this option only. It takes precedence over
+// the Select handler, which remains the fallback for other options.
+func (o Option[T]) Handler(h func(ctx context.Context, value T, fields map[string]string) (any, error)) Option[T] {
+	o.handler = h
+	return o
+}
+
+// Destructive marks the option as destructive. Every surface asks for
+// confirmation before calling its handler.
+func (o Option[T]) Destructive(destructive bool) Option[T] {
+	o.destructive = &destructive
+	return o
+}
+
+// isDestructive reports whether the option was marked destructive.
+func (o Option[T]) isDestructive() bool {
+	return o.destructive != nil && *o.destructive
+}
+
+// ReadOnly hints that the option does not modify its environment, so MCP
+// clients may run it without asking.
+func (o Option[T]) ReadOnly(readOnly bool) Option[T] {
+	o.readOnly = readOnly
+	return o
+}
+
+// Idempotent hints that repeating a call with the same arguments has no
+// additional effect.
+func (o Option[T]) Idempotent(idempotent bool) Option[T] {
+	o.idempotent = idempotent
+	return o
+}
+
+// OpenWorld hints whether the option interacts with external entities
+// (the web, third-party APIs) rather than a closed domain.
+func (o Option[T]) OpenWorld(openWorld bool) Option[T] {
+	o.openWorld = &openWorld
+	return o
+}
+
+// DisplayTitle sets a human-readable title shown by MCP clients instead of
+// the tool name.
+func (o Option[T]) DisplayTitle(title string) Option[T] {
+	o.displayTitle = title
+	return o
+}
+
+// Output declares the option's result type from an example value, such as
+// Output(SiteInfo{}) or Output([]SiteInfo{}). MCP clients receive it as an
+// output schema with structured results, TAP docs include it and the CLI
+// renders results as tables. MCP calls whose result doesn't match the
+// declared type fail with a tool error.
+//
+// Output panics if the example is not a struct, map or slice.
+func (o Option[T]) Output(example any) Option[T] {
+	schema, wrapped, err := outputSchemaFor(example)
+	if err == nil {
+		o.outputResolved, err = schema.Resolve(nil)
+	}
+	if err != nil {
+		panic(fmt.Sprintf("yeahno: Output: %v", err))
+	}
+	o.outputSchema = schema
+	o.outputWrapped = wrapped
+	return o
+}
+
+// RequireConfirm asks for confirmation with a custom prompt before calling
+// the handler. Placeholders like {domain} are replaced with field values.
+func (o Option[T]) RequireConfirm(prompt string) Option[T] {
+	o.confirmPrompt = prompt
+	return o
+}
+
+// Submenu makes the option open another menu instead of calling a handler.
+// The child's options become nested CLI subcommands and prefixed tools.
+func (o Option[T]) Submenu(menu Menu) Option[T] {
+	o.submenu = menu
+	return o
+}
+
+// name returns the option's tool name override, or its key.
+func (o Option[T]) name() string {
+	if o.toolName != "" {
+		return o.toolName
+	}
+	return o.Key
+}
+
+// Menu is a Select of any value type. It lets options of one Select open
+// a Select of another type via Option.Submenu.
+type Menu interface {
+	Run(ctx context.Context) (any, error)
+	ToSubcommands() ([]*cobra.Command, error)
+
+	run(ctx context.Context, sc scope) (any, error)
+	subcommands(sc scope) ([]*cobra.Command, error)
+	toolDefs(sc scope) ([]ToolDef, error)
+	resourceDefs(sc scope) ([]ResourceDef, error)
+	promptDefs(sc scope) ([]PromptDef, error)
+	optionInfos(sc scope) []optionInfo
+	onChange(l *listener)
+	offChange(l *listener)
+	httpTools(sc scope) ([]httpTool, error)
+	prefix() string
+}
+
 type Select[T comparable] struct {
 	title       string
 	description string
@@ -70,9 +207,18 @@ type Select[T comparable] struct {
 	validate    func(T) error
 	height      int
 	theme       *huh.Theme
+	cliTheme    *Theme
+	envPrefix   string
+	configFile  string
 
 	handler    func(ctx context.Context, value T, fields map[string]string) (any, error)
 	toolPrefix string
+	middleware []Middleware
+
+	// mu guards options, listeners and servers, which may change at runtime
+	mu        sync.RWMutex
+	listeners []*listener
+	servers   map[*mcp.Server]bool // servers with the MCP middleware installed
 }
 
 func NewSelect[T comparable]() *Select[T] {
@@ -97,7 +243,16 @@ func (s *Select[T]) Description(description string) *Select[T] {
 }
 
 func (s *Select[T]) Options(options ...Option[T]) *Select[T] {
+	s.mu.Lock()
+	for _, opt := range s.options {
+		s.detach(opt)
+	}
 	s.options = options
+	for _, opt := range options {
+		s.attach(opt)
+	}
+	s.mu.Unlock()
+	s.changed()
 	return s
 }
 
@@ -116,6 +271,13 @@ func (s *Select[T]) WithTheme(theme *huh.Theme) *Select[T] {
 	return s
 }
 
+// CLITheme sets the colors of tables printed by generated CLI commands.
+// Sub-menus inherit it. Defaults to DefaultTheme.
+func (s *Select[T]) CLITheme(theme *Theme) *Select[T] {
+	s.cliTheme = theme
+	return s
+}
+
 func (s *Select[T]) Handler(h func(ctx context.Context, value T, fields map[string]string) (any, error)) *Select[T] {
 	s.handler = h
 	return s
@@ -126,10 +288,66 @@ func (s *Select[T]) ToolPrefix(prefix string) *Select[T] {
 	return s
 }
 
+// exposedOptions returns the enabled options marked with MCP(true), or all
+// enabled options if none are marked.
+func (s *Select[T]) exposedOptions() []Option[T] {
+	all := s.currentOptions()
+	marked := slices.ContainsFunc(all, func(o Option[T]) bool { return o.mcp })
+
+	var opts []Option[T]
+	for _, o := range all {
+		if o.disabled || (marked && !o.mcp) {
+			continue
+		}
+		opts = append(opts, o)
+	}
+	return opts
+}
+
+// handlerFor returns the option's own handler, falling back to the Select
+// handler.
+func (s *Select[T]) handlerFor(opt Option[T]) func(ctx context.Context, value T, fields map[string]string) (any, error) {
+	if opt.handler != nil {
+		return opt.handler
+	}
+	return s.handler
+}
+
+// checkHandlers reports an error if any of opts has no handler to call.
+func (s *Select[T]) checkHandlers(opts []Option[T]) error {
+	for _, o := range opts {
+		if o.submenu == nil && s.handlerFor(o) == nil {
+			return fmt.Errorf("no handler configured")
+		}
+	}
+	return nil
+}
+
+func (s *Select[T]) prefix() string {
+	return s.toolPrefix
+}
+
 func (s *Select[T]) Run(ctx context.Context) (any, error) {
-	huhOpts := make([]huh.Option[T], len(s.options))
-	for i, o := range s.options {
-		huhOpts[i] = huh.NewOption(o.Key, o.Value)
+	return s.run(ctx, s.rootScope())
+}
+
+func (s *Select[T]) run(ctx context.Context, sc scope) (any, error) {
+	callerFor := func(o Option[T]) context.Context {
+		return callerContext(ctx, Invocation{Surface: SurfaceTUI}, joinToolName(sc.prefix, o.name()), o)
+	}
+	options := slices.DeleteFunc(s.currentOptions(), func(o Option[T]) bool {
+		return o.disabled || !o.visibleTo(callerFor(o))
+	})
+
+	unavailable := make(map[T]bool)
+	huhOpts := make([]huh.Option[T], len(options))
+	for i, o := range options {
+		key := o.Key
+		if !o.enabledFor(callerFor(o)) {
+			unavailable[o.Value] = true
+			key += " (unavailable)"
+		}
+		huhOpts[i] = huh.NewOption(key, o.Value)
 		if o.selected {
 			huhOpts[i] = huhOpts[i].Selected(true)
 		}
@@ -141,8 +359,20 @@ func (s *Select[T]) Run(ctx context.Context) (any, error) {
 		Options(huhOpts...).
 		Value(s.value)
 
-	if s.validate != nil {
-		sel = sel.Validate(s.validate)
+	validate := s.validate
+	if len(unavailable) > 0 {
+		validate = func(v T) error {
+			if unavailable[v] {
+				return fmt.Errorf("this option is not available")
+			}
+			if s.validate != nil {
+				return s.validate(v)
+			}
+			return nil
+		}
+	}
+	if validate != nil {
+		sel = sel.Validate(validate)
 	}
 	if s.height > 0 {
 		sel = sel.Height(s.height)
@@ -157,42 +387,80 @@ func (s *Select[T]) Run(ctx context.Context) (any, error) {
 	}
 
 	var selected *Option[T]
-	for i := range s.options {
-		if s.value != nil && s.options[i].Value == *s.value {
-			selected = &s.options[i]
+	for i := range options {
+		if s.value != nil && options[i].Value == *s.value {
+			selected = &options[i]
 			break
 		}
 	}
 
+	if selected != nil && selected.submenu != nil {
+		return selected.submenu.run(ctx, s.childScope(sc, *selected))
+	}
+
 	fields := make(map[string]string)
 	if selected != nil && len(selected.fields) > 0 {
-		for _, f := range selected.fields {
-			var val string
-			input := huh.NewInput().
-				Title(f.title).
-				Description(f.description).
-				Placeholder(f.placeholder).
-				Value(&val)
-
-			// Build combined validator for format + custom validation
-			input = input.Validate(f.buildValidator())
-			if f.charLimit > 0 {
-				input = input.CharLimit(f.charLimit)
-			}
+		if err := checkEnums(selected.fields); err != nil {
+			return nil, err
+		}
 
-			inputForm := huh.NewForm(huh.NewGroup(input))
-			if s.theme != nil {
-				inputForm = inputForm.WithTheme(s.theme)
-			}
-			if err := inputForm.Run(); err != nil {
+		// Pre-fill prompts from the environment and config file
+		config, err := loadConfig(sc.configFile, false)
+		if err != nil {
+			return nil, err
+		}
+		source := fieldSource{envPrefix: sc.envPrefix, config: config, tool: joinToolName(sc.prefix, selected.name())}
+		if fields, err = source.values(selected.fields); err != nil {
+			return nil, err
+		}
+
+		for _, f := range selected.fields {
+			val, err := s.promptField(ctx, f, fields)
+			if err != nil {
 				return nil, err
 			}
-			fields[f.key] = val
+			if val == "" && f.kind != KindString {
+				delete(fields, f.fieldKey())
+				continue
+			}
+			fields[f.fieldKey()] = val
 		}
 	}
 
-	if s.handler != nil && s.value != nil {
-		return s.handler(ctx, *s.value, fields)
+	if selected != nil && selected.needsConfirm() {
+		ok, err := promptConfirm(selected.confirmMessage(fields), s.theme)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrNotConfirmed
+		}
+	}
+
+	if selected != nil {
+		if s.handlerFor(*selected) != nil {
+			// Show progress and streamed chunks live while the handler runs
+			progress := newProgressPrinter(os.Stderr, isTerminal(os.Stderr))
+			ctx, _ := withStream(withProgress(ctx, progress.report), func(chunk any) error {
+				progress.interrupt(func() { printChunk(os.Stdout, chunk) })
+				return nil
+			})
+			result, err := s.invoke(ctx, sc, &Invocation{Surface: SurfaceTUI}, *selected, fields)
+			progress.finish()
+			if err != nil {
+				return nil, err
+			}
+			// Offer to save images and files; the caller prints the rest
+			if parts, ok, err := resultParts(result); ok {
+				if err != nil {
+					return nil, err
+				}
+				if err := saveParts(parts, s.theme); err != nil {
+					return nil, err
+				}
+			}
+			return result, nil
+		}
 	}
 
 	if s.value != nil {
@@ -201,6 +469,63 @@ func (s *Select[T]) Run(ctx context.Context) (any, error) {
 	return nil, nil
 }
 
+// promptField asks for a single field value using the widget that
+// matches the field's kind and returns it in canonical string form. A value
+// already in fields, such as one from the environment, is the initial value.
+func (s *Select[T]) promptField(ctx context.Context, f *Input, fields map[string]string) (string, error) {
+	var widget huh.Field
+	val := fields[f.fieldKey()]
+	flag := BoolField(fields, f.fieldKey())
+
+	switch {
+	case f.kind == KindBoolean:
+		widget = huh.NewConfirm().
+			Title(f.title).
+			Description(f.description).
+			Value(&flag)
+	case len(f.enum) > 0:
+		widget = huh.NewSelect[string]().
+			Title(f.title).
+			Description(f.description).
+			Options(huh.NewOptions(f.enum...)...).
+			Value(&val)
+	default:
+		input := huh.NewInput().
+			Title(f.title).
+			Description(f.description).
+			Placeholder(f.placeholder).
+			Value(&val)
+
+		// Build combined validator for kind + format + custom validation
+		input = input.Validate(f.buildValidator())
+		if f.charLimit > 0 {
+			input = input.CharLimit(f.charLimit)
+		}
+		if f.suggest != nil {
+			input = input.SuggestionsFunc(func() []string {
+				return f.suggest(ctx, val, fields)
+			}, &val)
+		}
+		widget = input
+	}
+
+	form := huh.NewForm(huh.NewGroup(widget))
+	if s.theme != nil {
+		form = form.WithTheme(s.theme)
+	}
+	if err := form.Run(); err != nil {
+		return "", err
+	}
+
+	if f.kind == KindBoolean {
+		return strconv.FormatBool(flag), nil
+	}
+	if val == "" {
+		return "", nil
+	}
+	return f.parseValue(val)
+}
+
 type Input struct {
 	title       string
 	description string
@@ -213,6 +538,15 @@ type Input struct {
 	key      string
 	required bool
 	format   string // JSON Schema format hint (e.g., "uri", "domain")
+	kind     FieldKind
+	enum     []string
+	suggest  SuggestFunc
+
+	// CLI argument and environment binding
+	positional bool
+	position   int
+	variadic   bool
+	env        string
 }
 
 func NewInput() *Input {
@@ -271,8 +605,40 @@ func (i *Input) Format(format string) *Input {
 	return i
 }
 
+// Integer makes the field accept whole numbers only.
+func (i *Input) Integer() *Input {
+	i.kind = KindInteger
+	return i
+}
+
+// Number makes the field accept any numeric value.
+func (i *Input) Number() *Input {
+	i.kind = KindNumber
+	return i
+}
+
+// Boolean makes the field a yes/no value.
+func (i *Input) Boolean() *Input {
+	i.kind = KindBoolean
+	return i
+}
+
+// Enum restricts a string field to one of the given values. Integer,
+// number and boolean fields can't have one: building tools, commands or
+// prompts for them fails.
+func (i *Input) Enum(values ...string) *Input {
+	i.enum = values
+	return i
+}
+
 func (i *Input) buildValidator() func(string) error {
 	return func(s string) error {
+		// Check the value parses as the field's kind
+		if s != "" {
+			if _, err := i.parseValue(s); err != nil {
+				return err
+			}
+		}
 		// Run format validation if specified
 		if i.format != "" {
 			if err := ValidateFormat(i.format, s); err != nil {
@@ -302,6 +668,11 @@ func (i *Input) Run() error {
 	if i.charLimit > 0 {
 		input = input.CharLimit(i.charLimit)
 	}
+	if i.suggest != nil && i.value != nil {
+		input = input.SuggestionsFunc(func() []string {
+			return i.suggest(context.Background(), *i.value, nil)
+		}, i.value)
+	}
 
 	form := huh.NewForm(huh.NewGroup(input))
 	if i.theme != nil {
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -1230,3 +1231,1548 @@ func TestToToolsCharLimitInSchema(t *testing.T) {
 		t.Error("note should not have maxLength when CharLimit is not set")
 	}
 }
+
+func TestToToolsTypedFields(t *testing.T) {
+	var choice string
+	var received map[string]string
+
+	menu := yeahno.NewSelect[string]().
+		Title("Crawl").
+		Options(
+			yeahno.NewOption("Crawl", "crawl").
+				WithField(yeahno.NewInput().Key("depth").Title("Depth").Integer()).
+				WithField(yeahno.NewInput().Key("rate").Title("Rate").Number().Required(false)).
+				WithField(yeahno.NewInput().Key("force").Title("Force").Boolean().Required(false)).
+				WithField(yeahno.NewInput().Key("mode").Title("Mode").Enum("fast", "full").Required(false)).
+				MCP(true),
+		).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			received = fields
+			return "ok", nil
+		})
+
+	tools, err := menu.ToTools()
+	if err != nil {
+		t.Fatalf("ToTools failed: %v", err)
+	}
+
+	schema := tools[0].Tool.InputSchema.(map[string]any)
+	props := schema["properties"].(map[string]any)
+	wantTypes := map[string]string{"depth": "integer", "rate": "number", "force": "boolean", "mode": "string"}
+	for key, want := range wantTypes {
+		prop := props[key].(map[string]any)
+		if prop["type"] != want {
+			t.Errorf("Expected %s type %q, got %v", key, want, prop["type"])
+		}
+	}
+	if enum, ok := props["mode"].(map[string]any)["enum"].([]any); !ok || len(enum) != 2 {
+		t.Errorf("Expected mode enum with 2 values, got %v", props["mode"])
+	}
+
+	handler := tools[0].Handler
+	call := func(args string) *mcp.CallToolResult {
+		result, _ := handler(context.Background(), &mcp.CallToolRequest{
+			Params: &mcp.CallToolParamsRaw{Name: "crawl", Arguments: json.RawMessage(args)},
+		})
+		return result
+	}
+
+	result := call(`{"depth": 5, "rate": 1.5, "force": true, "mode": "fast"}`)
+	if result.IsError {
+		t.Fatalf("Expected success, got: %v", getTextContent(result))
+	}
+	if yeahno.IntField(received, "depth") != 5 || yeahno.NumberField(received, "rate") != 1.5 || !yeahno.BoolField(received, "force") {
+		t.Errorf("Unexpected typed values: %v", received)
+	}
+
+	// Numbers sent as strings are coerced
+	result = call(`{"depth": "7"}`)
+	if result.IsError {
+		t.Fatalf("Expected success for string integer, got: %v", getTextContent(result))
+	}
+	if received["depth"] != "7" {
+		t.Errorf("Expected depth '7', got %q", received["depth"])
+	}
+
+	for _, args := range []string{
+		`{"depth": 2.5}`,
+		`{"depth": "five"}`,
+		`{"depth": 1, "force": "maybe"}`,
+		`{"depth": 1, "mode": "slow"}`,
+		`{"depth": 9223372036854775808}`,
+		`{"depth": "-9223372036854775809"}`,
+		`{"depth": 1e30}`,
+	} {
+		if result := call(args); !result.IsError {
+			t.Errorf("Expected error for %s", args)
+		}
+	}
+}
+
+func TestToToolsEnumRequiresString(t *testing.T) {
+	var choice string
+
+	menu := yeahno.NewSelect[string]().
+		Title("Crawl").
+		Options(
+			yeahno.NewOption("Crawl", "crawl").
+				WithField(yeahno.NewInput().Key("depth").Title("Depth").Integer().Enum("1", "2")).
+				MCP(true),
+		).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return "ok", nil
+		})
+
+	if _, err := menu.ToTools(); err == nil || !containsString(err.Error(), "only supported on string fields") {
+		t.Errorf("Expected enum error for integer field, got: %v", err)
+	}
+	if _, err := menu.ToCLI(); err == nil || !containsString(err.Error(), "only supported on string fields") {
+		t.Errorf("Expected enum error for integer flag, got: %v", err)
+	}
+}
+
+type addSiteArgs struct {
+	Domain string `json:"domain" yeahno:"title=Domain,format=domain"`
+	Depth  int    `json:"depth,omitempty" jsonschema:"crawl depth"`
+	Mode   string `json:"mode,omitempty" yeahno:"enum=fast|full"`
+	Force  bool   `json:"force,omitempty"`
+}
+
+func TestToToolsTypedOption(t *testing.T) {
+	var choice string
+	var received addSiteArgs
+
+	menu := yeahno.NewSelect[string]().
+		Title("Site").
+		ToolPrefix("site").
+		Options(
+			yeahno.NewTypedOption[string, addSiteArgs]("Add", "add").
+				Handle(func(ctx context.Context, action string, args addSiteArgs) (any, error) {
+					received = args
+					return fmt.Sprintf("added %s", args.Domain), nil
+				}).
+				Description("Add a site").
+				MCP(true),
+		).
+		Value(&choice)
+
+	tools, err := menu.ToTools()
+	if err != nil {
+		t.Fatalf("ToTools failed: %v", err)
+	}
+	if len(tools) != 1 || tools[0].Tool.Name != "site_add" {
+		t.Fatalf("Expected single 'site_add' tool, got %d", len(tools))
+	}
+
+	schema := tools[0].Tool.InputSchema.(map[string]any)
+	props := schema["properties"].(map[string]any)
+	if props["domain"].(map[string]any)["format"] != "hostname" {
+		t.Errorf("Expected domain format 'hostname', got %v", props["domain"])
+	}
+	if props["depth"].(map[string]any)["type"] != "integer" {
+		t.Errorf("Expected depth type 'integer', got %v", props["depth"])
+	}
+	if props["depth"].(map[string]any)["description"] != "crawl depth" {
+		t.Errorf("Expected depth description from jsonschema tag, got %v", props["depth"])
+	}
+	if required, ok := schema["required"].([]string); !ok || len(required) != 1 || required[0] != "domain" {
+		t.Errorf("Expected only 'domain' required, got %v", schema["required"])
+	}
+
+	result, _ := tools[0].Handler(context.Background(), &mcp.CallToolRequest{
+		Params: &mcp.CallToolParamsRaw{
+			Name:      "site_add",
+			Arguments: json.RawMessage(`{"domain": "example.com", "depth": 3, "mode": "full", "force": true}`),
+		},
+	})
+	if result.IsError {
+		t.Fatalf("Expected success, got: %v", getTextContent(result))
+	}
+	assertTextContent(t, result, "added example.com")
+	want := addSiteArgs{Domain: "example.com", Depth: 3, Mode: "full", Force: true}
+	if received != want {
+		t.Errorf("Expected %+v, got %+v", want, received)
+	}
+
+	result, _ = tools[0].Handler(context.Background(), &mcp.CallToolRequest{
+		Params: &mcp.CallToolParamsRaw{
+			Name:      "site_add",
+			Arguments: json.RawMessage(`{"domain": "localhost"}`),
+		},
+	})
+	if !result.IsError {
+		t.Error("Expected error for invalid domain")
+	}
+}
+
+func TestToToolsSubmenu(t *testing.T) {
+	var root, siteChoice string
+	var userChoice int
+
+	sites := yeahno.NewSelect[string]().
+		Title("Sites").
+		ToolPrefix("site").
+		Options(
+			yeahno.NewOption("Add", "add").
+				WithField(yeahno.NewInput().Key("domain").Title("Domain")).
+				MCP(true),
+		).
+		Value(&siteChoice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return "site " + action + " " + fields["domain"], nil
+		})
+
+	users := yeahno.NewSelect[int]().
+		Title("Users").
+		Options(yeahno.NewOption("Ban", 1).MCP(true)).
+		Value(&userChoice).
+		Handler(func(ctx context.Context, action int, fields map[string]string) (any, error) {
+			return fmt.Sprintf("user action %d", action), nil
+		})
+
+	menu := yeahno.NewSelect[string]().
+		Title("Admin").
+		ToolPrefix("admin").
+		Options(
+			yeahno.NewOption("Sites", "sites").Submenu(sites).MCP(true),
+			yeahno.NewOption("User", "user").Submenu(users).MCP(true),
+		).
+		Value(&root)
+
+	tools, err := menu.ToTools()
+	if err != nil {
+		t.Fatalf("ToTools failed: %v", err)
+	}
+
+	byName := make(map[string]yeahno.ToolDef)
+	for _, td := range tools {
+		byName[td.Tool.Name] = td
+	}
+	if len(byName) != 2 {
+		t.Fatalf("Expected 2 tools, got %v", byName)
+	}
+	// Child ToolPrefix is composed with the parent's; without one the option name is used
+	if _, ok := byName["admin_site_add"]; !ok {
+		t.Errorf("Expected 'admin_site_add' tool, got %v", byName)
+	}
+	ban, ok := byName["admin_user_ban"]
+	if !ok {
+		t.Fatalf("Expected 'admin_user_ban' tool, got %v", byName)
+	}
+
+	result, _ := ban.Handler(context.Background(), &mcp.CallToolRequest{
+		Params: &mcp.CallToolParamsRaw{Name: "admin_user_ban", Arguments: json.RawMessage(`{}`)},
+	})
+	assertTextContent(t, result, "user action 1")
+}
+
+func TestToToolsOptionHandler(t *testing.T) {
+	var choice string
+
+	menu := yeahno.NewSelect[string]().
+		Title("Site").
+		Options(
+			yeahno.NewOption("Add", "add").
+				Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+					return "option handler", nil
+				}).
+				MCP(true),
+			yeahno.NewOption("List", "list").MCP(true),
+		).
+		Value(&choice)
+
+	// Without a Select handler, "list" has nothing to call
+	if _, err := menu.ToTools(); err == nil || !containsString(err.Error(), "no handler configured") {
+		t.Fatalf("Expected 'no handler configured' error, got: %v", err)
+	}
+
+	menu.Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+		return "select handler", nil
+	})
+
+	env := setupMCPServerClient(t, menu)
+	defer env.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := env.session.CallTool(ctx, &mcp.CallToolParams{Name: "add", Arguments: json.RawMessage(`{}`)})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	assertTextContent(t, result, "option handler")
+
+	result, err = env.session.CallTool(ctx, &mcp.CallToolParams{Name: "list", Arguments: json.RawMessage(`{}`)})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	assertTextContent(t, result, "select handler")
+}
+
+func TestToToolsOnlyOptionHandlers(t *testing.T) {
+	var choice string
+
+	menu := yeahno.NewSelect[string]().
+		Title("Site").
+		Options(
+			yeahno.NewOption("Add", "add").
+				Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+					return "added", nil
+				}).
+				MCP(true),
+			yeahno.NewOption("Internal", "internal"),
+		).
+		Value(&choice)
+
+	tools, err := menu.ToTools()
+	if err != nil {
+		t.Fatalf("ToTools failed: %v", err)
+	}
+	if len(tools) != 1 {
+		t.Fatalf("Expected 1 tool, got %d", len(tools))
+	}
+}
+
+func TestToToolsMiddleware(t *testing.T) {
+	var choice, childChoice string
+	var calls []string
+
+	logging := func(next yeahno.HandlerFunc) yeahno.HandlerFunc {
+		return func(ctx context.Context, call *yeahno.Call) (any, error) {
+			calls = append(calls, fmt.Sprintf("%s:%s:%v", call.Surface, call.Tool, call.Value))
+			return next(ctx, call)
+		}
+	}
+	deny := func(next yeahno.HandlerFunc) yeahno.HandlerFunc {
+		return func(ctx context.Context, call *yeahno.Call) (any, error) {
+			if call.Fields["user"] == "mallory" {
+				return nil, fmt.Errorf("forbidden")
+			}
+			call.Fields["user"] = strings.ToUpper(call.Fields["user"])
+			return next(ctx, call)
+		}
+	}
+
+	child := yeahno.NewSelect[string]().
+		Title("Child").
+		ToolPrefix("child").
+		Options(yeahno.NewOption("Ping", "ping").MCP(true)).
+		Value(&childChoice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return "pong", nil
+		})
+
+	menu := yeahno.NewSelect[string]().
+		Title("Root").
+		Options(
+			yeahno.NewOption("Greet", "greet").
+				WithField(yeahno.NewInput().Key("user").Title("User")).
+				MCP(true),
+			yeahno.NewOption("Child", "child").Submenu(child).MCP(true),
+		).
+		Value(&choice).
+		Use(logging, deny).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return "hello " + fields["user"], nil
+		})
+
+	env := setupMCPServerClient(t, menu)
+	defer env.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := env.session.CallTool(ctx, &mcp.CallToolParams{Name: "greet", Arguments: json.RawMessage(`{"user": "alice"}`)})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	assertTextContent(t, result, "hello ALICE")
+
+	result, err = env.session.CallTool(ctx, &mcp.CallToolParams{Name: "greet", Arguments: json.RawMessage(`{"user": "mallory"}`)})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if !result.IsError {
+		t.Error("Expected middleware to reject call")
+	}
+
+	// Parent middleware also wraps submenu handlers
+	result, err = env.session.CallTool(ctx, &mcp.CallToolParams{Name: "child_ping", Arguments: json.RawMessage(`{}`)})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	assertTextContent(t, result, "pong")
+
+	want := []string{"mcp:greet:greet", "mcp:greet:greet", "mcp:child_ping:ping"}
+	if fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Errorf("Expected calls %v, got %v", want, calls)
+	}
+}
+
+func TestToToolsInvocation(t *testing.T) {
+	var choice string
+	var inv *yeahno.Invocation
+
+	menu := yeahno.NewSelect[string]().
+		Title("Who").
+		ToolPrefix("who").
+		Options(yeahno.NewOption("Am I", "whoami").MCP(true)).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			inv = yeahno.InvocationFrom(ctx)
+			return "ok", nil
+		})
+
+	env := setupMCPServerClient(t, menu)
+	defer env.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := env.session.CallTool(ctx, &mcp.CallToolParams{Name: "who_am_i", Arguments: json.RawMessage(`{}`)}); err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if inv == nil {
+		t.Fatal("Expected invocation in handler context")
+	}
+	if inv.Surface != yeahno.SurfaceMCP || inv.Tool != "who_am_i" || inv.Value != "whoami" {
+		t.Errorf("Unexpected invocation: %+v", inv)
+	}
+	if inv.Client == nil || inv.Client.Name != "test-client" {
+		t.Errorf("Expected client 'test-client', got %+v", inv.Client)
+	}
+	if inv.SessionID == "" || inv.Session == nil {
+		t.Error("Expected MCP session details")
+	}
+
+	if yeahno.InvocationFrom(context.Background()) != nil {
+		t.Error("Expected nil invocation outside a handler")
+	}
+}
+
+func TestToToolsDestructiveConfirm(t *testing.T) {
+	var choice string
+	var deleted []string
+
+	menu := yeahno.NewSelect[string]().
+		Title("Site").
+		Options(
+			yeahno.NewOption("Delete", "delete").
+				WithField(yeahno.NewInput().Key("domain").Title("Domain")).
+				RequireConfirm("Delete site {domain}?").
+				MCP(true),
+		).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			deleted = append(deleted, fields["domain"])
+			return "deleted", nil
+		})
+
+	tools, err := menu.ToTools()
+	if err != nil {
+		t.Fatalf("ToTools failed: %v", err)
+	}
+	props := tools[0].Tool.InputSchema.(map[string]any)["properties"].(map[string]any)
+	if _, ok := props["confirm"]; !ok {
+		t.Error("Expected 'confirm' property in destructive tool schema")
+	}
+
+	env := setupMCPServerClient(t, menu)
+	defer env.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Client without elicitation must pass confirm: true
+	result, err := env.session.CallTool(ctx, &mcp.CallToolParams{Name: "delete", Arguments: json.RawMessage(`{"domain": "example.com"}`)})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if !result.IsError {
+		t.Fatal("Expected unconfirmed destructive call to fail")
+	}
+	assertTextContentContains(t, result, "Delete site example.com?")
+
+	// Only the JSON boolean confirms
+	result, err = env.session.CallTool(ctx, &mcp.CallToolParams{Name: "delete", Arguments: json.RawMessage(`{"domain": "example.com", "confirm": "true"}`)})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if !result.IsError {
+		t.Fatal("Expected string confirm to be rejected")
+	}
+
+	result, err = env.session.CallTool(ctx, &mcp.CallToolParams{Name: "delete", Arguments: json.RawMessage(`{"domain": "example.com", "confirm": true}`)})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	assertTextContent(t, result, "deleted")
+
+	// Client with elicitation is asked instead
+	var asked string
+	accept := false
+	session := connectClient(t, env.server.URL, &mcp.ClientOptions{
+		ElicitationHandler: func(ctx context.Context, req *mcp.ElicitRequest) (*mcp.ElicitResult, error) {
+			asked = req.Params.Message
+			return &mcp.ElicitResult{Action: "accept", Content: map[string]any{"confirm": accept}}, nil
+		},
+	})
+	defer session.Close()
+
+	result, err = session.CallTool(ctx, &mcp.CallToolParams{Name: "delete", Arguments: json.RawMessage(`{"domain": "a.com"}`)})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if !result.IsError || asked != "Delete site a.com?" {
+		t.Errorf("Expected declined elicitation for 'Delete site a.com?', got %q / %v", asked, getTextContent(result))
+	}
+
+	// A model-supplied confirm doesn't skip the user
+	asked = ""
+	result, err = session.CallTool(ctx, &mcp.CallToolParams{Name: "delete", Arguments: json.RawMessage(`{"domain": "c.com", "confirm": true}`)})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if !result.IsError || asked != "Delete site c.com?" {
+		t.Errorf("Expected elicitation despite confirm: true, got %q / %v", asked, getTextContent(result))
+	}
+
+	list, err := session.ListTools(ctx, nil)
+	if err != nil {
+		t.Fatalf("ListTools failed: %v", err)
+	}
+	props = list.Tools[0].InputSchema.(map[string]any)["properties"].(map[string]any)
+	if _, ok := props["confirm"]; ok {
+		t.Error("Expected no 'confirm' property for a client that can elicit")
+	}
+
+	accept = true
+	result, err = session.CallTool(ctx, &mcp.CallToolParams{Name: "delete", Arguments: json.RawMessage(`{"domain": "b.com"}`)})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	assertTextContent(t, result, "deleted")
+
+	if fmt.Sprint(deleted) != "[example.com b.com]" {
+		t.Errorf("Unexpected deletions: %v", deleted)
+	}
+}
+
+func connectClient(t *testing.T, endpoint string, opts *mcp.ClientOptions) *mcp.ClientSession {
+	t.Helper()
+
+	client := mcp.NewClient(&mcp.Implementation{
+		Name:    "test-client",
+		Version: "1.0.0",
+	}, opts)
+
+	session, err := client.Connect(context.Background(), &mcp.StreamableClientTransport{
+		Endpoint:   endpoint,
+		MaxRetries: -1,
+	}, nil)
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	return session
+}
+
+func TestToToolsAnnotations(t *testing.T) {
+	var choice string
+
+	menu := yeahno.NewSelect[string]().
+		Title("Site").
+		Options(
+			yeahno.NewOption("List", "list").
+				ReadOnly(true).
+				Idempotent(true).
+				OpenWorld(false).
+				DisplayTitle("List Sites").
+				MCP(true),
+			yeahno.NewOption("Delete", "delete").Destructive(true).MCP(true),
+			yeahno.NewOption("Plain", "plain").MCP(true),
+			yeahno.NewOption("Update", "update").Idempotent(true).MCP(true),
+			yeahno.NewOption("Rename", "rename").Destructive(false).MCP(true),
+		).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return action, nil
+		})
+
+	tools, err := menu.ToTools()
+	if err != nil {
+		t.Fatalf("ToTools failed: %v", err)
+	}
+
+	list, del, plain, update, rename := tools[0].Tool, tools[1].Tool, tools[2].Tool, tools[3].Tool, tools[4].Tool
+	if list.Annotations == nil || !list.Annotations.ReadOnlyHint || !list.Annotations.IdempotentHint {
+		t.Fatalf("Expected read-only idempotent annotations, got %+v", list.Annotations)
+	}
+	if list.Annotations.OpenWorldHint == nil || *list.Annotations.OpenWorldHint {
+		t.Errorf("Expected openWorldHint=false, got %v", list.Annotations.OpenWorldHint)
+	}
+	if list.Title != "List Sites" || list.Annotations.Title != "List Sites" {
+		t.Errorf("Expected title 'List Sites', got %q / %q", list.Title, list.Annotations.Title)
+	}
+	if del.Annotations == nil || del.Annotations.DestructiveHint == nil || !*del.Annotations.DestructiveHint {
+		t.Errorf("Expected destructiveHint=true, got %+v", del.Annotations)
+	}
+	if plain.Annotations != nil {
+		t.Errorf("Expected no annotations, got %+v", plain.Annotations)
+	}
+	// Unset stays unset, so clients keep assuming the worst
+	if update.Annotations == nil || update.Annotations.DestructiveHint != nil {
+		t.Errorf("Expected no destructiveHint, got %+v", update.Annotations)
+	}
+	if rename.Annotations == nil || rename.Annotations.DestructiveHint == nil || *rename.Annotations.DestructiveHint {
+		t.Errorf("Expected explicit destructiveHint=false, got %+v", rename.Annotations)
+	}
+	if list.Annotations.DestructiveHint != nil {
+		t.Errorf("Expected no destructiveHint on read-only tool, got %v", *list.Annotations.DestructiveHint)
+	}
+}
+
+type siteInfo struct {
+	Domain string `json:"domain"`
+	Active bool   `json:"active"`
+}
+
+func TestToToolsStructuredOutput(t *testing.T) {
+	var choice string
+
+	menu := yeahno.NewSelect[string]().
+		Title("Site").
+		Options(
+			yeahno.NewOption("List", "list").Output([]siteInfo{}).MCP(true),
+			yeahno.NewOption("Get", "get").Output(siteInfo{}).MCP(true),
+			yeahno.NewOption("Broken", "broken").Output(siteInfo{}).MCP(true),
+		).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			switch action {
+			case "list":
+				return []siteInfo{{Domain: "example.com", Active: true}}, nil
+			case "broken":
+				return "plain text", nil
+			}
+			return siteInfo{Domain: "example.com"}, nil
+		})
+
+	tools, err := menu.ToTools()
+	if err != nil {
+		t.Fatalf("ToTools failed: %v", err)
+	}
+	schema, ok := tools[0].Tool.OutputSchema.(map[string]any)
+	if !ok {
+		t.Fatalf("Expected output schema, got %T", tools[0].Tool.OutputSchema)
+	}
+	props, _ := schema["properties"].(map[string]any)
+	if _, ok := props["items"]; !ok {
+		t.Fatalf("Expected list output wrapped in items, got %v", schema)
+	}
+
+	env := setupMCPServerClient(t, menu)
+	defer env.Close()
+
+	result, err := env.session.CallTool(context.Background(), &mcp.CallToolParams{Name: "list"})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	structured, ok := result.StructuredContent.(map[string]any)
+	if !ok {
+		t.Fatalf("Expected structured content, got %T", result.StructuredContent)
+	}
+	items, _ := structured["items"].([]any)
+	if len(items) != 1 {
+		t.Fatalf("Expected 1 item, got %v", structured)
+	}
+	assertTextContentContains(t, result, `"domain":"example.com"`)
+
+	result, err = env.session.CallTool(context.Background(), &mcp.CallToolParams{Name: "get"})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if got, _ := result.StructuredContent.(map[string]any); got["domain"] != "example.com" {
+		t.Errorf("Expected structured domain, got %v", result.StructuredContent)
+	}
+
+	// A result that doesn't match the declared output is a tool error
+	result, err = env.session.CallTool(context.Background(), &mcp.CallToolParams{Name: "broken"})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if !result.IsError || result.StructuredContent != nil {
+		t.Errorf("Expected error without structured content, got %v / %v", getTextContent(result), result.StructuredContent)
+	}
+}
+
+func TestToToolsRichContent(t *testing.T) {
+	var choice string
+	png := []byte("\x89PNG\r\n\x1a\n")
+
+	menu := yeahno.NewSelect[string]().
+		Title("Report").
+		Options(yeahno.NewOption("Chart", "chart").MCP(true)).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return yeahno.Multi(
+				"Weekly report",
+				yeahno.Image(png, ""),
+				yeahno.File("report.csv", strings.NewReader("site,pages\nexample.com,12\n")),
+				yeahno.ResourceLink("https://example.com/reports/weekly.pdf"),
+			), nil
+		})
+
+	env := setupMCPServerClient(t, menu)
+	defer env.Close()
+
+	result, err := env.session.CallTool(context.Background(), &mcp.CallToolParams{Name: "chart"})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if len(result.Content) != 4 {
+		t.Fatalf("Expected 4 content parts, got %d", len(result.Content))
+	}
+	if text, ok := result.Content[0].(*mcp.TextContent); !ok || text.Text != "Weekly report" {
+		t.Errorf("Expected text part, got %#v", result.Content[0])
+	}
+	img, ok := result.Content[1].(*mcp.ImageContent)
+	if !ok || img.MIMEType != "image/png" || string(img.Data) != string(png) {
+		t.Errorf("Expected png image part, got %#v", result.Content[1])
+	}
+	res, ok := result.Content[2].(*mcp.EmbeddedResource)
+	if !ok || res.Resource.URI != "file:///report.csv" || !strings.Contains(res.Resource.Text, "example.com,12") {
+		t.Errorf("Expected embedded csv, got %#v", result.Content[2])
+	}
+	link, ok := result.Content[3].(*mcp.ResourceLink)
+	if !ok || link.URI != "https://example.com/reports/weekly.pdf" || link.Name != "weekly.pdf" {
+		t.Errorf("Expected resource link, got %#v", result.Content[3])
+	}
+}
+
+func TestToToolsElicitMissingFields(t *testing.T) {
+	var choice string
+
+	menu := yeahno.NewSelect[string]().
+		Title("Site").
+		Options(
+			yeahno.NewOption("Add", "add").
+				WithField(yeahno.NewInput().Key("domain").Title("Domain").Format("domain")).
+				WithField(yeahno.NewInput().Key("depth").Title("Depth").Integer()).
+				MCP(true),
+		).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return fields["domain"] + " " + fields["depth"], nil
+		})
+
+	env := setupMCPServerClient(t, menu)
+	defer env.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Clients without elicitation still get the validation error
+	result, err := env.session.CallTool(ctx, &mcp.CallToolParams{Name: "add", Arguments: map[string]any{"depth": 3}})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if !result.IsError {
+		t.Fatalf("Expected missing field error, got %q", getTextContent(result))
+	}
+
+	var requests []*mcp.ElicitParams
+	answers := []map[string]any{
+		{"domain": "not a domain"},
+		{"domain": "example.com"},
+	}
+	session := connectClient(t, env.server.URL, &mcp.ClientOptions{
+		ElicitationHandler: func(ctx context.Context, req *mcp.ElicitRequest) (*mcp.ElicitResult, error) {
+			content := answers[len(requests)]
+			requests = append(requests, req.Params)
+			return &mcp.ElicitResult{Action: "accept", Content: content}, nil
+		},
+	})
+	defer session.Close()
+
+	result, err = session.CallTool(ctx, &mcp.CallToolParams{Name: "add", Arguments: map[string]any{"depth": 3}})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	assertTextContent(t, result, "example.com 3")
+
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 elicitation rounds, got %d", len(requests))
+	}
+	schema, _ := json.Marshal(requests[0].RequestedSchema)
+	if strings.Contains(string(schema), "depth") || !strings.Contains(string(schema), `"domain"`) {
+		t.Errorf("Expected only missing fields to be requested, got %s", schema)
+	}
+	if !strings.Contains(requests[1].Message, "domain: invalid domain") {
+		t.Errorf("Expected second round to explain the error, got %q", requests[1].Message)
+	}
+}
+
+func TestToToolsProgress(t *testing.T) {
+	var choice string
+
+	menu := yeahno.NewSelect[string]().
+		Title("Site").
+		Options(yeahno.NewOption("Crawl", "crawl").MCP(true)).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			yeahno.Progress(ctx).Report(1, 2, "page 1")
+			yeahno.Progress(ctx).Report(2, 2, "page 2")
+			return "crawled", nil
+		})
+
+	env := setupMCPServerClient(t, menu)
+	defer env.Close()
+
+	var mu sync.Mutex
+	var got []string
+	session := connectClient(t, env.server.URL, &mcp.ClientOptions{
+		ProgressNotificationHandler: func(ctx context.Context, req *mcp.ProgressNotificationClientRequest) {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, fmt.Sprintf("%v %g/%g %s", req.Params.ProgressToken, req.Params.Progress, req.Params.Total, req.Params.Message))
+		},
+	})
+	defer session.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	params := &mcp.CallToolParams{Name: "crawl", Meta: mcp.Meta{"progressToken": "crawl-1"}}
+	result, err := session.CallTool(ctx, params)
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	assertTextContent(t, result, "crawled")
+
+	// Notifications may arrive just after the result
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(got)
+		mu.Unlock()
+		if n == 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if fmt.Sprint(got) != "[crawl-1 1/2 page 1 crawl-1 2/2 page 2]" {
+		t.Errorf("Unexpected progress notifications: %v", got)
+	}
+}
+
+func TestToToolsStream(t *testing.T) {
+	var choice string
+
+	menu := yeahno.NewSelect[string]().
+		Title("Logs").
+		Options(yeahno.NewOption("Tail", "tail").MCP(true)).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return func(yield func(string, error) bool) {
+				for _, line := range []string{"line 1", "line 2"} {
+					if !yield(line, nil) {
+						return
+					}
+				}
+			}, nil
+		})
+
+	// Called directly there is no session to notify
+	tools, err := menu.ToTools()
+	if err != nil {
+		t.Fatalf("ToTools failed: %v", err)
+	}
+	result, _ := tools[0].Handler(context.Background(), &mcp.CallToolRequest{
+		Params: &mcp.CallToolParamsRaw{Name: "tail", Arguments: json.RawMessage(`{}`), Meta: mcp.Meta{"progressToken": "tail-0"}},
+	})
+	assertTextContent(t, result, "line 1\nline 2")
+
+	env := setupMCPServerClient(t, menu)
+	defer env.Close()
+
+	var mu sync.Mutex
+	var got []string
+	progress := 0
+	session := connectClient(t, env.server.URL, &mcp.ClientOptions{
+		LoggingMessageHandler: func(ctx context.Context, req *mcp.LoggingMessageRequest) {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, fmt.Sprint(req.Params.Data))
+		},
+		ProgressNotificationHandler: func(ctx context.Context, req *mcp.ProgressNotificationClientRequest) {
+			mu.Lock()
+			defer mu.Unlock()
+			progress++
+		},
+	})
+	defer session.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := session.SetLoggingLevel(ctx, &mcp.SetLoggingLevelParams{Level: "info"}); err != nil {
+		t.Fatalf("SetLoggingLevel failed: %v", err)
+	}
+
+	// The progress token is left to Progress(ctx), so chunks don't move it
+	params := &mcp.CallToolParams{Name: "tail", Meta: mcp.Meta{"progressToken": "tail-1"}}
+	result, err = session.CallTool(ctx, params)
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	// Delivered chunks aren't repeated in the result
+	assertTextContent(t, result, "Output was streamed as log messages.")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(got)
+		mu.Unlock()
+		if n == 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if fmt.Sprint(got) != "[line 1 line 2]" {
+		t.Errorf("Unexpected chunk notifications: %v", got)
+	}
+	if progress != 0 {
+		t.Errorf("Expected no progress notifications for chunks, got %d", progress)
+	}
+}
+
+func TestToToolsStreamWithoutLogLevel(t *testing.T) {
+	var choice string
+
+	menu := yeahno.NewSelect[string]().
+		Title("Logs").
+		Options(yeahno.NewOption("Tail", "tail").MCP(true)).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			yeahno.Stream(ctx).Send("line 1")
+			yeahno.Stream(ctx).Send("line 2")
+			return "done", nil
+		})
+
+	env := setupMCPServerClient(t, menu)
+	defer env.Close()
+
+	session := connectClient(t, env.server.URL, nil)
+	defer session.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Without a log level the chunks can't go out live, so they lead the result
+	result, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "tail"})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if len(result.Content) != 2 {
+		t.Fatalf("Expected 2 content items, got %d", len(result.Content))
+	}
+	for i, want := range []string{"line 1\nline 2", "done"} {
+		text, ok := result.Content[i].(*mcp.TextContent)
+		if !ok || text.Text != want {
+			t.Errorf("Content[%d]: expected %q, got %#v", i, want, result.Content[i])
+		}
+	}
+}
+
+func TestRegisterMCPResources(t *testing.T) {
+	var choice string
+
+	menu := yeahno.NewSelect[string]().
+		Title("Site").
+		Options(
+			yeahno.NewOption("Show", "show").
+				WithField(yeahno.NewInput().Key("domain").Title("Domain")).
+				Description("Show a site").
+				AsResource("sites://{domain}").
+				MCP(true),
+			yeahno.NewOption("List", "list").
+				Output([]siteInfo{}).
+				AsResource("sites://all").
+				MCP(true),
+		).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			if action == "list" {
+				return []siteInfo{{Domain: "example.com", Active: true}}, nil
+			}
+			return "site " + fields["domain"], nil
+		})
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "test-server", Version: "1.0.0"}, nil)
+	if err := menu.RegisterMCP(server); err != nil {
+		t.Fatalf("RegisterMCP failed: %v", err)
+	}
+	ts := httptest.NewServer(mcp.NewStreamableHTTPHandler(func(r *http.Request) *mcp.Server { return server }, nil))
+	defer ts.Close()
+	session := connectClient(t, ts.URL, nil)
+	defer session.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	templates, err := session.ListResourceTemplates(ctx, nil)
+	if err != nil {
+		t.Fatalf("ListResourceTemplates failed: %v", err)
+	}
+	if len(templates.ResourceTemplates) != 1 || templates.ResourceTemplates[0].URITemplate != "sites://{domain}" {
+		t.Fatalf("Unexpected templates: %+v", templates.ResourceTemplates)
+	}
+	resources, err := session.ListResources(ctx, nil)
+	if err != nil {
+		t.Fatalf("ListResources failed: %v", err)
+	}
+	if len(resources.Resources) != 1 || resources.Resources[0].MIMEType != "application/json" {
+		t.Fatalf("Unexpected resources: %+v", resources.Resources)
+	}
+
+	res, err := session.ReadResource(ctx, &mcp.ReadResourceParams{URI: "sites://example.com"})
+	if err != nil {
+		t.Fatalf("ReadResource failed: %v", err)
+	}
+	if res.Contents[0].Text != "site example.com" {
+		t.Errorf("Expected 'site example.com', got %q", res.Contents[0].Text)
+	}
+
+	res, err = session.ReadResource(ctx, &mcp.ReadResourceParams{URI: "sites://all"})
+	if err != nil {
+		t.Fatalf("ReadResource failed: %v", err)
+	}
+	if !strings.Contains(res.Contents[0].Text, `"domain":"example.com"`) {
+		t.Errorf("Expected JSON list, got %q", res.Contents[0].Text)
+	}
+
+	// The options are still tools
+	tools, err := session.ListTools(ctx, nil)
+	if err != nil || len(tools.Tools) != 2 {
+		t.Fatalf("Expected 2 tools, got %v (%v)", tools, err)
+	}
+}
+
+func TestToResourcesUnknownVariable(t *testing.T) {
+	var choice string
+
+	menu := yeahno.NewSelect[string]().
+		Options(yeahno.NewOption("Show", "show").AsResource("sites://{domain}").MCP(true)).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return action, nil
+		})
+
+	if _, err := menu.ToResources(); err == nil || !strings.Contains(err.Error(), "domain is not a field") {
+		t.Fatalf("Expected unknown variable error, got %v", err)
+	}
+}
+
+func TestRegisterPrompts(t *testing.T) {
+	var choice string
+
+	menu := yeahno.NewSelect[string]().
+		Title("Site").
+		ToolPrefix("site").
+		Options(
+			yeahno.NewOption("Audit", "audit").
+				WithField(yeahno.NewInput().Key("domain").Title("Domain")).
+				Prompt("Audit {{.Args.domain}} with the {{.Tool}} tool and summarize any problems.").
+				MCP(true),
+			yeahno.NewOption("List", "list").Description("List all sites").MCP(true),
+		).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return action, nil
+		})
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "test-server", Version: "1.0.0"}, nil)
+	if err := menu.RegisterPrompts(server); err != nil {
+		t.Fatalf("RegisterPrompts failed: %v", err)
+	}
+	ts := httptest.NewServer(mcp.NewStreamableHTTPHandler(func(r *http.Request) *mcp.Server { return server }, nil))
+	defer ts.Close()
+	session := connectClient(t, ts.URL, nil)
+	defer session.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	prompts, err := session.ListPrompts(ctx, nil)
+	if err != nil {
+		t.Fatalf("ListPrompts failed: %v", err)
+	}
+	if len(prompts.Prompts) != 2 {
+		t.Fatalf("Expected 2 prompts, got %d", len(prompts.Prompts))
+	}
+	audit := prompts.Prompts[0]
+	if audit.Name != "site_audit" || len(audit.Arguments) != 1 || !audit.Arguments[0].Required {
+		t.Fatalf("Unexpected prompt: %+v", audit)
+	}
+
+	res, err := session.GetPrompt(ctx, &mcp.GetPromptParams{Name: "site_audit", Arguments: map[string]string{"domain": "example.com"}})
+	if err != nil {
+		t.Fatalf("GetPrompt failed: %v", err)
+	}
+	if text := res.Messages[0].Content.(*mcp.TextContent).Text; text != "Audit example.com with the site_audit tool and summarize any problems." {
+		t.Errorf("Unexpected prompt text: %q", text)
+	}
+
+	res, err = session.GetPrompt(ctx, &mcp.GetPromptParams{Name: "site_list"})
+	if err != nil {
+		t.Fatalf("GetPrompt failed: %v", err)
+	}
+	if text := res.Messages[0].Content.(*mcp.TextContent).Text; text != "Use the site_list tool (List all sites)." {
+		t.Errorf("Unexpected default prompt text: %q", text)
+	}
+
+	if _, err := session.GetPrompt(ctx, &mcp.GetPromptParams{Name: "site_audit"}); err == nil {
+		t.Error("Expected error for missing required argument")
+	}
+}
+
+func TestCompletionHandler(t *testing.T) {
+	var choice string
+	sites := []string{"example.com", "example.org", "other.net"}
+
+	menu := yeahno.NewSelect[string]().
+		Title("Site").
+		Options(
+			yeahno.NewOption("Show", "show").
+				WithField(yeahno.NewInput().Key("domain").Title("Domain").
+					Suggest(func(ctx context.Context, partial string, fields map[string]string) []string {
+						var out []string
+						for _, s := range sites {
+							if strings.HasPrefix(s, partial) {
+								out = append(out, s)
+							}
+						}
+						return out
+					})).
+				WithField(yeahno.NewInput().Key("mode").Title("Mode").Enum("fast", "full").Required(false)).
+				AsResource("sites://{domain}").
+				MCP(true),
+		).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return action, nil
+		})
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "test-server", Version: "1.0.0"}, &mcp.ServerOptions{
+		CompletionHandler: menu.CompletionHandler(),
+	})
+	if err := menu.RegisterMCP(server); err != nil {
+		t.Fatalf("RegisterMCP failed: %v", err)
+	}
+	if err := menu.RegisterPrompts(server); err != nil {
+		t.Fatalf("RegisterPrompts failed: %v", err)
+	}
+	ts := httptest.NewServer(mcp.NewStreamableHTTPHandler(func(r *http.Request) *mcp.Server { return server }, nil))
+	defer ts.Close()
+	session := connectClient(t, ts.URL, nil)
+	defer session.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := session.Complete(ctx, &mcp.CompleteParams{
+		Ref:      &mcp.CompleteReference{Type: "ref/resource", URI: "sites://{domain}"},
+		Argument: mcp.CompleteParamsArgument{Name: "domain", Value: "exa"},
+	})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if fmt.Sprint(res.Completion.Values) != "[example.com example.org]" {
+		t.Errorf("Unexpected resource completions: %v", res.Completion.Values)
+	}
+
+	res, err = session.Complete(ctx, &mcp.CompleteParams{
+		Ref:      &mcp.CompleteReference{Type: "ref/prompt", Name: "show"},
+		Argument: mcp.CompleteParamsArgument{Name: "mode", Value: "fu"},
+	})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if fmt.Sprint(res.Completion.Values) != "[full]" {
+		t.Errorf("Unexpected enum completions: %v", res.Completion.Values)
+	}
+}
+
+func TestBindToolListChanged(t *testing.T) {
+	var choice string
+
+	menu := yeahno.NewSelect[string]().
+		Title("Site").
+		Options(
+			yeahno.NewOption("List", "list").MCP(true),
+			yeahno.NewOption("Purge", "purge").Disabled(true).MCP(true),
+		).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return action, nil
+		})
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "test-server", Version: "1.0.0"}, nil)
+	if err := menu.Bind(server); err != nil {
+		t.Fatalf("Bind failed: %v", err)
+	}
+	ts := httptest.NewServer(mcp.NewStreamableHTTPHandler(func(r *http.Request) *mcp.Server { return server }, nil))
+	defer ts.Close()
+
+	changed := make(chan struct{}, 10)
+	session := connectClient(t, ts.URL, &mcp.ClientOptions{
+		ToolListChangedHandler: func(ctx context.Context, req *mcp.ToolListChangedRequest) {
+			changed <- struct{}{}
+		},
+	})
+	defer session.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	toolNames := func() string {
+		t.Helper()
+		res, err := session.ListTools(ctx, nil)
+		if err != nil {
+			t.Fatalf("ListTools failed: %v", err)
+		}
+		var names []string
+		for _, tool := range res.Tools {
+			names = append(names, tool.Name)
+		}
+		return strings.Join(names, ",")
+	}
+
+	if got := toolNames(); got != "list" {
+		t.Fatalf("Expected only 'list', got %q", got)
+	}
+
+	menu.SetEnabled("purge", true).
+		AddOptions(yeahno.NewOption("Show", "show").MCP(true)).
+		RemoveOption("list")
+
+	select {
+	case <-changed:
+	case <-ctx.Done():
+		t.Fatal("Expected tools/list_changed notification")
+	}
+	if got := toolNames(); got != "purge,show" {
+		t.Fatalf("Expected 'purge,show', got %q", got)
+	}
+}
+
+func TestBindOptionsSubmenu(t *testing.T) {
+	var choice, userChoice string
+
+	handler := func(ctx context.Context, action string, fields map[string]string) (any, error) {
+		return action, nil
+	}
+	menu := yeahno.NewSelect[string]().
+		Title("Admin").
+		Options(yeahno.NewOption("List", "list").MCP(true)).
+		Value(&choice).
+		Handler(handler)
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "test-server", Version: "1.0.0"}, nil)
+	if err := menu.Bind(server); err != nil {
+		t.Fatalf("Bind failed: %v", err)
+	}
+	ts := httptest.NewServer(mcp.NewStreamableHTTPHandler(func(r *http.Request) *mcp.Server { return server }, nil))
+	defer ts.Close()
+	session := connectClient(t, ts.URL, nil)
+	defer session.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// A submenu set with Options after Bind is watched like one added
+	// with AddOptions
+	users := yeahno.NewSelect[string]().
+		Title("Users").
+		ToolPrefix("user").
+		Options(yeahno.NewOption("Ban", "ban").MCP(true)).
+		Value(&userChoice).
+		Handler(handler)
+	menu.Options(
+		yeahno.NewOption("List", "list").MCP(true),
+		yeahno.NewOption("Users", "users").Submenu(users).MCP(true),
+	)
+	users.AddOptions(yeahno.NewOption("Unban", "unban").MCP(true))
+
+	res, err := session.ListTools(ctx, nil)
+	if err != nil {
+		t.Fatalf("ListTools failed: %v", err)
+	}
+	var names []string
+	for _, tool := range res.Tools {
+		names = append(names, tool.Name)
+	}
+	if got := strings.Join(names, ","); got != "list,user_ban,user_unban" {
+		t.Fatalf("Expected 'list,user_ban,user_unban', got %q", got)
+	}
+}
+
+func TestRegisterMCPVisibleWhenPrompts(t *testing.T) {
+	var choice string
+
+	isOnCall := func(ctx context.Context) bool {
+		return yeahno.InvocationFrom(ctx).Header.Get("X-Role") == "oncall"
+	}
+	menu := yeahno.NewSelect[string]().
+		Title("Site").
+		Options(
+			yeahno.NewOption("List", "list").AsResource("sites://all").MCP(true),
+			yeahno.NewOption("Show", "show").
+				WithField(yeahno.NewInput().Key("domain").Title("Domain").Enum("example.com", "example.org")).
+				AsResource("sites://{domain}").
+				MCP(true).
+				VisibleWhen(isOnCall),
+		).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return action, nil
+		})
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "test-server", Version: "1.0.0"}, &mcp.ServerOptions{
+		CompletionHandler: menu.CompletionHandler(),
+	})
+	if err := menu.RegisterMCP(server); err != nil {
+		t.Fatalf("RegisterMCP failed: %v", err)
+	}
+	if err := menu.RegisterPrompts(server); err != nil {
+		t.Fatalf("RegisterPrompts failed: %v", err)
+	}
+	handler := mcp.NewStreamableHTTPHandler(func(r *http.Request) *mcp.Server { return server }, nil)
+	agentServer := httptest.NewServer(handler)
+	defer agentServer.Close()
+	onCallServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set("X-Role", "oncall")
+		handler.ServeHTTP(w, r)
+	}))
+	defer onCallServer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	agent := connectClient(t, agentServer.URL, nil)
+	defer agent.Close()
+	onCall := connectClient(t, onCallServer.URL, nil)
+	defer onCall.Close()
+
+	lists := func(session *mcp.ClientSession) string {
+		t.Helper()
+		var names []string
+		prompts, err := session.ListPrompts(ctx, nil)
+		if err != nil {
+			t.Fatalf("ListPrompts failed: %v", err)
+		}
+		for _, p := range prompts.Prompts {
+			names = append(names, "prompt:"+p.Name)
+		}
+		resources, err := session.ListResources(ctx, nil)
+		if err != nil {
+			t.Fatalf("ListResources failed: %v", err)
+		}
+		for _, r := range resources.Resources {
+			names = append(names, "resource:"+r.Name)
+		}
+		templates, err := session.ListResourceTemplates(ctx, nil)
+		if err != nil {
+			t.Fatalf("ListResourceTemplates failed: %v", err)
+		}
+		for _, r := range templates.ResourceTemplates {
+			names = append(names, "template:"+r.Name)
+		}
+		return strings.Join(names, ",")
+	}
+
+	if got := lists(agent); got != "prompt:list,resource:list" {
+		t.Errorf("agent lists = %q, want prompt:list,resource:list", got)
+	}
+	if got := lists(onCall); got != "prompt:list,prompt:show,resource:list,template:show" {
+		t.Errorf("on-call lists = %q, want prompt:list,prompt:show,resource:list,template:show", got)
+	}
+
+	if _, err := agent.GetPrompt(ctx, &mcp.GetPromptParams{Name: "show", Arguments: map[string]string{"domain": "example.com"}}); err == nil {
+		t.Error("Expected GetPrompt show to fail for the agent")
+	}
+	if _, err := onCall.GetPrompt(ctx, &mcp.GetPromptParams{Name: "show", Arguments: map[string]string{"domain": "example.com"}}); err != nil {
+		t.Errorf("GetPrompt show failed for on-call: %v", err)
+	}
+
+	complete := func(session *mcp.ClientSession) string {
+		t.Helper()
+		res, err := session.Complete(ctx, &mcp.CompleteParams{
+			Ref:      &mcp.CompleteReference{Type: "ref/prompt", Name: "show"},
+			Argument: mcp.CompleteParamsArgument{Name: "domain", Value: "exa"},
+		})
+		if err != nil {
+			t.Fatalf("Complete failed: %v", err)
+		}
+		return fmt.Sprint(res.Completion.Values)
+	}
+	if got := complete(agent); got != "[]" {
+		t.Errorf("agent completions = %s, want none", got)
+	}
+	if got := complete(onCall); got != "[example.com example.org]" {
+		t.Errorf("on-call completions = %s, want [example.com example.org]", got)
+	}
+}
+
+func TestBindRemovedSubmenu(t *testing.T) {
+	var choice, userChoice string
+
+	handler := func(ctx context.Context, action string, fields map[string]string) (any, error) {
+		return action, nil
+	}
+	users := yeahno.NewSelect[string]().
+		Title("Users").
+		ToolPrefix("user").
+		Options(yeahno.NewOption("Ban", "ban").MCP(true)).
+		Value(&userChoice).
+		Handler(handler)
+	menu := yeahno.NewSelect[string]().
+		Title("Admin").
+		Options(
+			yeahno.NewOption("List", "list").MCP(true),
+			yeahno.NewOption("Users", "users").Submenu(users).MCP(true),
+		).
+		Value(&choice).
+		Handler(handler)
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "test-server", Version: "1.0.0"}, nil)
+	if err := menu.Bind(server); err != nil {
+		t.Fatalf("Bind failed: %v", err)
+	}
+	ts := httptest.NewServer(mcp.NewStreamableHTTPHandler(func(r *http.Request) *mcp.Server { return server }, nil))
+	defer ts.Close()
+
+	changed := make(chan struct{}, 10)
+	session := connectClient(t, ts.URL, &mcp.ClientOptions{
+		ToolListChangedHandler: func(ctx context.Context, req *mcp.ToolListChangedRequest) {
+			changed <- struct{}{}
+		},
+	})
+	defer session.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	menu.RemoveOption("users")
+	select {
+	case <-changed:
+	case <-ctx.Done():
+		t.Fatal("Expected tools/list_changed notification")
+	}
+
+	// The removed submenu no longer resyncs the server
+	users.AddOptions(yeahno.NewOption("Unban", "unban").MCP(true))
+	select {
+	case <-changed:
+		t.Fatal("Expected no tools/list_changed notification from a removed submenu")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestRegisterMCPVisibleWhen(t *testing.T) {
+	var choice string
+
+	isOnCall := func(ctx context.Context) bool {
+		return yeahno.InvocationFrom(ctx).Header.Get("X-Role") == "oncall"
+	}
+	menu := yeahno.NewSelect[string]().
+		Title("Site").
+		Options(
+			yeahno.NewOption("List", "list").MCP(true),
+			yeahno.NewOption("Purge", "purge").MCP(true).VisibleWhen(isOnCall),
+			yeahno.NewOption("Restart", "restart").
+				WithField(yeahno.NewInput().Key("reason").Title("Reason")).
+				RequireConfirm("Restart?").
+				MCP(true).
+				EnabledWhen(isOnCall),
+		).
+		Value(&choice).
+		Handler(func(ctx context.Context, action string, fields map[string]string) (any, error) {
+			return action, nil
+		})
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "test-server", Version: "1.0.0"}, nil)
+	if err := menu.RegisterMCP(server); err != nil {
+		t.Fatalf("RegisterMCP failed: %v", err)
+	}
+	handler := mcp.NewStreamableHTTPHandler(func(r *http.Request) *mcp.Server { return server }, nil)
+	agentServer := httptest.NewServer(handler)
+	defer agentServer.Close()
+	onCallServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set("X-Role", "oncall")
+		handler.ServeHTTP(w, r)
+	}))
+	defer onCallServer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	toolNames := func(session *mcp.ClientSession) string {
+		t.Helper()
+		res, err := session.ListTools(ctx, nil)
+		if err != nil {
+			t.Fatalf("ListTools failed: %v", err)
+		}
+		var names []string
+		for _, tool := range res.Tools {
+			names = append(names, tool.Name)
+		}
+		return strings.Join(names, ",")
+	}
+
+	// The agent can elicit, but must not be asked about unavailable tools
+	elicited := 0
+	agent := connectClient(t, agentServer.URL, &mcp.ClientOptions{
+		ElicitationHandler: func(ctx context.Context, req *mcp.ElicitRequest) (*mcp.ElicitResult, error) {
+			elicited++
+			return &mcp.ElicitResult{Action: "accept", Content: map[string]any{"reason": "stuck", "confirm": true}}, nil
+		},
+	})
+	defer agent.Close()
+	onCall := connectClient(t, onCallServer.URL, nil)
+	defer onCall.Close()
+
+	if got := toolNames(agent); got != "list,restart" {
+		t.Fatalf("agent tools = %q, want list,restart", got)
+	}
+	if got := toolNames(onCall); got != "list,purge,restart" {
+		t.Fatalf("on-call tools = %q, want list,purge,restart", got)
+	}
+
+	for _, name := range []string{"purge", "restart"} {
+		res, err := agent.CallTool(ctx, &mcp.CallToolParams{Name: name, Arguments: map[string]any{}})
+		if err != nil {
+			t.Fatalf("CallTool %s failed: %v", name, err)
+		}
+		if !res.IsError {
+			t.Fatalf("Expected %s to fail for the agent", name)
+		}
+	}
+	if elicited != 0 {
+		t.Errorf("Expected no elicitation for unavailable tools, got %d", elicited)
+	}
+
+	res, err := onCall.CallTool(ctx, &mcp.CallToolParams{Name: "purge", Arguments: map[string]any{}})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if res.IsError {
+		t.Fatalf("Expected purge to succeed for on-call, got %v", res.Content)
+	}
+}